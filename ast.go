@@ -18,8 +18,16 @@
 package zcore
 
 import (
+	"fmt"
 	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os"
+	"path"
 	"path/filepath"
+	"strconv"
+	"strings"
 )
 
 // AssertFuncType to assert interface fields as function type and try return name
@@ -32,6 +40,108 @@ func AssertFuncType(field *ast.Field) (name string, ft *ast.FuncType, ok bool) {
 	return
 }
 
+// Param represents parameter or result of function type
+type Param struct {
+	// Name is empty for unnamed parameter
+	Name string
+	// Type is type source text as written. variadic parameter type is like "...int"
+	Type     string
+	Variadic bool
+}
+
+// FuncTypeInfo extracts parameters and results of function type from file.
+// parameters declared together like "a, b int" would be expanded as separated Param
+func FuncTypeInfo(ft *ast.FuncType, file *File) (params, results []Param) {
+	return fieldListParams(ft.Params, file), fieldListParams(ft.Results, file)
+}
+
+func fieldListParams(fl *ast.FieldList, file *File) (params []Param) {
+	if fl == nil {
+		return
+	}
+	for _, field := range fl.List {
+		_, variadic := field.Type.(*ast.Ellipsis)
+		typ := string(file.Node(field.Type))
+		if len(field.Names) == 0 {
+			params = append(params, Param{Type: typ, Variadic: variadic})
+			continue
+		}
+		for _, name := range field.Names {
+			params = append(params, Param{Name: name.Name, Type: typ, Variadic: variadic})
+		}
+	}
+	return
+}
+
+// ZeroReturnStatement returns return statement with zero values of results for stub method body.
+// named results would be returned by naked "return". results types should be qualified for destination file
+// such as converted by FixPackage and package selectors imported as "." in dstImports would be removed.
+// zero value of named type without known underlying type is "*new(T)" which is valid for any type
+func ZeroReturnStatement(results []Param, dstImports Imports) string {
+	if len(results) == 0 {
+		return "return"
+	}
+	values := make([]string, 0, len(results))
+	for _, result := range results {
+		if len(result.Name) > 0 {
+			return "return"
+		}
+		values = append(values, zeroValue(result.Type, dstImports))
+	}
+	return "return " + strings.Join(values, ", ")
+}
+
+// StubStatement returns ZeroReturnStatement of results or panic statement if panicking is true
+func StubStatement(results []Param, dstImports Imports, panicking bool) string {
+	if panicking {
+		return `panic("not implemented")`
+	}
+	return ZeroReturnStatement(results, dstImports)
+}
+
+// zeroValue returns zero value expression of type source text
+func zeroValue(typ string, dstImports Imports) string {
+	expr, err := parser.ParseExpr(typ)
+	if err != nil {
+		return "*new(" + typ + ")"
+	}
+	switch t := expr.(type) {
+	case *ast.Ident:
+		switch t.Name {
+		case "bool":
+			return "false"
+		case "string":
+			return `""`
+		case "int", "int8", "int16", "int32", "int64", "uint", "uint8", "uint16", "uint32", "uint64", "uintptr",
+			"float32", "float64", "complex64", "complex128", "byte", "rune":
+			return "0"
+		case "error", "any":
+			return "nil"
+		}
+	case *ast.SelectorExpr:
+		// package imported as "." in destination
+		if pkg, ok := t.X.(*ast.Ident); ok {
+			for p, name := range dstImports {
+				if name == "." && path.Base(p) == pkg.Name {
+					typ = t.Sel.Name
+					break
+				}
+			}
+		}
+	case *ast.ArrayType:
+		// fixed length array
+		if t.Len != nil {
+			return typ + "{}"
+		}
+		return "nil"
+	case *ast.StarExpr, *ast.MapType, *ast.ChanType, *ast.FuncType, *ast.InterfaceType:
+		return "nil"
+	case *ast.StructType:
+		return typ + "{}"
+	}
+	return "*new(" + typ + ")"
+}
+
 func ExtractAnonymousName(spec ast.Expr) (name *ast.Ident) {
 	switch t := spec.(type) {
 	case *ast.StarExpr:
@@ -72,7 +182,31 @@ func ExtractStructFieldsNames(typ *ast.StructType) (names []string) {
 }
 
 // LookupTypSpec lookup typename in package src path.
+// unresolved type and skipped unparsable files would be collected as warnings in strict resolution mode
 func LookupTypSpec(name, dir, pkgPath string) (expr ast.Expr, srcFile *File) {
+	if expr, srcFile = lookupTypSpec(name, dir, pkgPath); expr == nil {
+		strictWarnf("unresolved type %s in package %q from %s", name, pkgPath, dir)
+	}
+	return
+}
+
+// walkPackageFiles walks golang files of package directory as WalkPackage
+// but skips unparsable files which would be collected as warnings in strict resolution mode
+func walkPackageFiles(dir string, fn func(file *File) error) {
+	_ = WalkDir(dir, func(filename string) error {
+		if !IsGoFile(filename) {
+			return nil
+		}
+		f, err := ParseFile(filename)
+		if err != nil {
+			strictWarnf("skipped file %s: %v", filename, err)
+			return nil
+		}
+		return fn(f)
+	})
+}
+
+func lookupTypSpec(name, dir, pkgPath string) (expr ast.Expr, srcFile *File) {
 	if len(pkgPath) == 0 {
 		return
 	}
@@ -82,7 +216,7 @@ func LookupTypSpec(name, dir, pkgPath string) (expr ast.Expr, srcFile *File) {
 		return
 	}
 
-	_, _ = WalkPackage(pkgDir, func(file *File) (err error) {
+	walkPackageFiles(pkgDir, func(file *File) (err error) {
 		object := file.Lookup(name)
 		if object == nil || object.Decl == nil {
 			return
@@ -91,9 +225,9 @@ func LookupTypSpec(name, dir, pkgPath string) (expr ast.Expr, srcFile *File) {
 		if spec, ok := object.Decl.(*ast.TypeSpec); ok {
 			switch typ := spec.Type.(type) {
 			case *ast.SelectorExpr:
-				expr, srcFile = LookupTypSpec(typ.Sel.Name, dir, file.Imports().Which(UnsafeBytes2String(file.Node(typ.X))))
+				expr, srcFile = lookupTypSpec(typ.Sel.Name, dir, file.Imports().Which(UnsafeBytes2String(file.Node(typ.X))))
 			case *ast.Ident:
-				expr, srcFile = LookupTypSpec(typ.Name, dir, pkgPath)
+				expr, srcFile = lookupTypSpec(typ.Name, dir, pkgPath)
 			default:
 				expr, srcFile = typ, file
 			}
@@ -103,3 +237,632 @@ func LookupTypSpec(name, dir, pkgPath string) (expr ast.Expr, srcFile *File) {
 	})
 	return
 }
+
+// ResolveConstraint resolves type parameter constraint into union terms.
+// interface literal constraint like "interface{ ~int | ~string }" would be expanded with embedded elements
+// and named constraint like "constraints.Ordered" would be resolved via LookupTypSpec.
+// returns terms as written like "~int" in names and term types without tilde like "int" in underlying.
+// predeclared constraint "any" and "comparable" would be returned in names without underlying
+func ResolveConstraint(expr ast.Expr, file *File) (names []string, underlying []string) {
+	return resolveConstraint(expr, file, make(map[string]bool))
+}
+
+func resolveConstraint(expr ast.Expr, file *File, visited map[string]bool) (names []string, underlying []string) {
+	add := func(n, u []string) {
+		names, underlying = append(names, n...), append(underlying, u...)
+	}
+
+	switch typ := expr.(type) {
+	case *ast.ParenExpr:
+		return resolveConstraint(typ.X, file, visited)
+	case *ast.InterfaceType:
+		// embedded elements only
+		for _, field := range typ.Methods.List {
+			if len(field.Names) == 0 {
+				add(resolveConstraint(field.Type, file, visited))
+			}
+		}
+	case *ast.BinaryExpr:
+		// union terms
+		if typ.Op == token.OR {
+			add(resolveConstraint(typ.X, file, visited))
+			add(resolveConstraint(typ.Y, file, visited))
+		}
+	case *ast.UnaryExpr:
+		// tilde term. compare with operator string to keep compatible with toolchains before generics
+		if typ.Op.String() == "~" {
+			add([]string{string(file.Node(typ))}, []string{string(file.Node(typ.X))})
+		}
+	case *ast.Ident, *ast.SelectorExpr:
+		text := string(file.Node(typ))
+		if ident, ok := typ.(*ast.Ident); ok && types.Universe.Lookup(ident.Name) != nil {
+			if ident.Name == "any" || ident.Name == "comparable" {
+				return []string{text}, nil
+			}
+			return []string{text}, []string{text}
+		}
+
+		spec, srcFile := lookupConstraintSpec(typ, file)
+		key := fmt.Sprintf("%p", spec)
+		if spec == nil || visited[key] {
+			return []string{text}, []string{text}
+		}
+		visited[key] = true
+
+		if _, ok := spec.(*ast.InterfaceType); ok {
+			return resolveConstraint(spec, srcFile, visited)
+		}
+		return []string{text}, []string{string(srcFile.Node(spec))}
+	default:
+		text := string(file.Node(typ))
+		return []string{text}, []string{text}
+	}
+	return
+}
+
+// lookupConstraintSpec lookup named constraint type spec by identifier in file package or selector package
+func lookupConstraintSpec(expr ast.Expr, file *File) (spec ast.Expr, srcFile *File) {
+	dir := filepath.Dir(file.Path)
+	switch typ := expr.(type) {
+	case *ast.Ident:
+		return LookupTypSpec(typ.Name, dir, GetImportPath(file.Path))
+	case *ast.SelectorExpr:
+		if x, ok := typ.X.(*ast.Ident); ok {
+			return LookupTypSpec(typ.Sel.Name, dir, file.Imports().Which(x.Name))
+		}
+	}
+	return
+}
+
+type (
+	// ResolvedType represents type tree node resolved by ResolveStructTree
+	ResolvedType struct {
+		// Type is go type source text as written like "[]*pkg.T"
+		Type string
+		// Kind is one of "basic" "struct" "pointer" "array" "slice" "map" "interface" "func" "chan" or "named".
+		// "named" only for named type not expanded by depth limit, cycle or unresolved
+		Kind string
+		// Named is qualified name like "import/path.T" of named type, empty for unnamed type
+		Named string
+		// Cycle is true if named type has already been expanded in ancestors
+		Cycle bool
+
+		Fields []*ResolvedField // fields of struct
+		Key    *ResolvedType    // key of map
+		Elem   *ResolvedType    // element of pointer, array, slice, map or chan
+	}
+
+	// ResolvedField represents struct field of ResolvedType
+	ResolvedField struct {
+		Name     string
+		Embedded bool
+		Tag      string
+		Type     *ResolvedType
+	}
+)
+
+// ResolveStructTree resolves annotated struct declaration fields types recursively
+// and expands named types via package lookup. cycle types would not be expanded again.
+// maxDepth limits depth of named types expansion, zero or negative means no limit
+func ResolveStructTree(decl *AnnotatedDecl, maxDepth int) (*ResolvedType, error) {
+	if decl.TypeSpec == nil {
+		return nil, fmt.Errorf("%s is not type declaration", decl.Name())
+	}
+	if _, ok := decl.TypeSpec.Type.(*ast.StructType); !ok {
+		return nil, fmt.Errorf("%s is not struct type", decl.Name())
+	}
+	r := &typeResolver{maxDepth: maxDepth, visited: make(map[string]bool)}
+	named := qualifiedTypeName(GetImportPath(decl.File.Path), decl.Name())
+	r.visited[named] = true
+	typ := r.resolve(decl.TypeSpec.Type, decl.File, 0)
+	typ.Type, typ.Named = decl.Name(), named
+	return typ, nil
+}
+
+type typeResolver struct {
+	maxDepth int
+	visited  map[string]bool
+}
+
+func (r *typeResolver) resolve(expr ast.Expr, file *File, depth int) (typ *ResolvedType) {
+	typ = &ResolvedType{Type: string(file.Node(expr))}
+	switch t := expr.(type) {
+	case *ast.ParenExpr:
+		return r.resolve(t.X, file, depth)
+	case *ast.StarExpr:
+		typ.Kind, typ.Elem = "pointer", r.resolve(t.X, file, depth)
+	case *ast.ArrayType:
+		typ.Kind, typ.Elem = "slice", r.resolve(t.Elt, file, depth)
+		if t.Len != nil {
+			typ.Kind = "array"
+		}
+	case *ast.MapType:
+		typ.Kind, typ.Key, typ.Elem = "map", r.resolve(t.Key, file, depth), r.resolve(t.Value, file, depth)
+	case *ast.ChanType:
+		typ.Kind, typ.Elem = "chan", r.resolve(t.Value, file, depth)
+	case *ast.FuncType:
+		typ.Kind = "func"
+	case *ast.InterfaceType:
+		typ.Kind = "interface"
+	case *ast.StructType:
+		typ.Kind = "struct"
+		for _, field := range t.Fields.List {
+			ft := r.resolve(field.Type, file, depth)
+			tag := ""
+			if field.Tag != nil {
+				tag, _ = strconv.Unquote(field.Tag.Value)
+			}
+			if len(field.Names) == 0 {
+				name := ""
+				if ident := ExtractAnonymousName(field.Type); ident != nil {
+					name = ident.Name
+				}
+				typ.Fields = append(typ.Fields, &ResolvedField{Name: name, Embedded: true, Tag: tag, Type: ft})
+				continue
+			}
+			for _, name := range field.Names {
+				typ.Fields = append(typ.Fields, &ResolvedField{Name: name.Name, Tag: tag, Type: ft})
+			}
+		}
+	case *ast.Ident:
+		if types.Universe.Lookup(t.Name) != nil {
+			typ.Kind = "basic"
+			return
+		}
+		r.resolveNamed(typ, t.Name, GetImportPath(file.Path), file, file, depth)
+	case *ast.SelectorExpr:
+		if x, ok := t.X.(*ast.Ident); ok {
+			r.resolveNamed(typ, t.Sel.Name, file.Imports().Which(x.Name), file, nil, depth)
+		} else {
+			typ.Kind = "named"
+		}
+	default:
+		typ.Kind = "named"
+	}
+	return
+}
+
+// resolveNamed lookups named type spec referenced from file and expands underlying type into typ.
+// local is the file to lookup first for same package type
+func (r *typeResolver) resolveNamed(typ *ResolvedType, name, pkgPath string, file, local *File, depth int) {
+	typ.Kind, typ.Named = "named", qualifiedTypeName(pkgPath, name)
+	if r.visited[typ.Named] {
+		typ.Cycle = true
+		return
+	}
+	if r.maxDepth > 0 && depth >= r.maxDepth {
+		return
+	}
+
+	spec, srcFile := lookupTypeSpecDecl(name, pkgPath, filepath.Dir(file.Path), local)
+	if spec == nil {
+		strictWarnf("unresolved type %s in package %q", name, pkgPath)
+		return
+	}
+
+	r.visited[typ.Named] = true
+	defer delete(r.visited, typ.Named)
+
+	underlying := r.resolve(spec.Type, srcFile, depth+1)
+	if underlying.Kind != "named" {
+		typ.Kind, typ.Fields, typ.Key, typ.Elem = underlying.Kind, underlying.Fields, underlying.Key, underlying.Elem
+	}
+}
+
+// lookupTypeSpecDecl lookups type spec by name in provided file and then package of pkgPath from dir
+func lookupTypeSpecDecl(name, pkgPath, dir string, file *File) (spec *ast.TypeSpec, srcFile *File) {
+	if file != nil {
+		if object := file.Lookup(name); object != nil {
+			if spec, ok := object.Decl.(*ast.TypeSpec); ok {
+				return spec, file
+			}
+		}
+	}
+	if len(pkgPath) == 0 {
+		return
+	}
+	pkgDir := GetPackageImportDir(pkgPath, dir)
+	if len(pkgDir) == 0 {
+		return
+	}
+	walkPackageFiles(pkgDir, func(f *File) error {
+		if object := f.Lookup(name); object != nil {
+			if s, ok := object.Decl.(*ast.TypeSpec); ok {
+				spec, srcFile = s, f
+				return filepath.SkipDir
+			}
+		}
+		return nil
+	})
+	return
+}
+
+func qualifiedTypeName(pkgPath, name string) string {
+	if len(pkgPath) == 0 {
+		return name
+	}
+	return pkgPath + "." + name
+}
+
+// InterfaceMethod represents method in interface method set
+type InterfaceMethod struct {
+	Name  string
+	Field *ast.Field
+	File  *File
+
+	// Signature is method signature with qualified types and without parameter names
+	// like "func(context.Context, github.com/x/y.T) error". it is used to compare methods
+	Signature string
+}
+
+// ResolveInterfaceMethods resolves method set of interface type declared in file.
+// embedded interfaces would be expanded recursively via package lookup.
+// methods with same name and signature from different embedded interfaces would be merged,
+// returns error if methods with same name have mismatched signatures
+func ResolveInterfaceMethods(typ *ast.InterfaceType, file *File) (methods []InterfaceMethod, err error) {
+	index := make(map[string]int)
+	var conflicts []string
+	add := func(method InterfaceMethod) {
+		i, exist := index[method.Name]
+		if !exist {
+			index[method.Name] = len(methods)
+			methods = append(methods, method)
+			return
+		}
+		if prev := methods[i]; prev.Signature != method.Signature {
+			Appendf(&conflicts, "%s: %s and %s", method.Name, prev.Signature, method.Signature)
+		}
+	}
+
+	resolveInterfaceMethods(typ, file, make(map[string]bool), add)
+	if len(conflicts) > 0 {
+		return nil, fmt.Errorf("conflicted interface methods:\n%s", strings.Join(conflicts, "\n"))
+	}
+	return
+}
+
+func resolveInterfaceMethods(typ *ast.InterfaceType, file *File, visited map[string]bool, fn func(method InterfaceMethod)) {
+	if typ.Methods == nil {
+		return
+	}
+	for _, field := range typ.Methods.List {
+		if name, ft, ok := AssertFuncType(field); ok {
+			fn(InterfaceMethod{Name: name, Field: field, File: file, Signature: qualifiedExprString(ft, file)})
+			continue
+		}
+
+		// embedded interface
+		var name, pkgPath string
+		local := file
+		switch t := field.Type.(type) {
+		case *ast.Ident:
+			if t.Name == "error" {
+				// predeclared error interface
+				fn(InterfaceMethod{Name: "Error", Field: field, File: file, Signature: "func() string"})
+				continue
+			}
+			if types.Universe.Lookup(t.Name) != nil {
+				continue
+			}
+			name, pkgPath = t.Name, GetImportPath(file.Path)
+		case *ast.SelectorExpr:
+			x, ok := t.X.(*ast.Ident)
+			if !ok {
+				continue
+			}
+			name, pkgPath, local = t.Sel.Name, file.Imports().Which(x.Name), nil
+		default:
+			// type set elements of constraint
+			continue
+		}
+
+		key := qualifiedTypeName(pkgPath, name)
+		if visited[key] {
+			continue
+		}
+		visited[key] = true
+
+		spec, srcFile := lookupTypeSpecDecl(name, pkgPath, filepath.Dir(file.Path), local)
+		if spec == nil {
+			strictWarnf("unresolved embedded interface %s in package %q", name, pkgPath)
+			continue
+		}
+		if it, ok := spec.Type.(*ast.InterfaceType); ok {
+			resolveInterfaceMethods(it, srcFile, visited, fn)
+		}
+	}
+}
+
+// TypeRef represents named type declared in package
+type TypeRef struct {
+	PkgPath string
+	Name    string
+	File    *File
+
+	// Pointer represents only pointer of type implements interface with pointer receiver methods
+	Pointer bool
+}
+
+// FindImplementations walks golang files from searchRoot as WalkTree and finds struct types
+// whose method sets satisfy interface ifaceName of package ifacePkgPath.
+// methods are compared by qualified signatures and promoted methods of embedded fields are not counted.
+// implementations are returned in walking order
+func FindImplementations(ifacePkgPath, ifaceName, searchRoot string) (refs []TypeRef, err error) {
+	dir, err := filepath.Abs(searchRoot)
+	if err != nil {
+		return
+	}
+	if stat, e := os.Stat(dir); e == nil && !stat.IsDir() {
+		dir = filepath.Dir(dir)
+	}
+
+	spec, srcFile := lookupTypeSpecDecl(ifaceName, ifacePkgPath, dir, nil)
+	if spec == nil {
+		return nil, fmt.Errorf("interface %s not found", qualifiedTypeName(ifacePkgPath, ifaceName))
+	}
+	it, ok := spec.Type.(*ast.InterfaceType)
+	if !ok {
+		return nil, fmt.Errorf("%s is not interface", qualifiedTypeName(ifacePkgPath, ifaceName))
+	}
+	methods, err := ResolveInterfaceMethods(it, srcFile)
+	if err != nil {
+		return
+	}
+
+	type methodSet struct {
+		values   map[string]string // value receiver methods
+		pointers map[string]string // pointer receiver methods
+	}
+
+	var structs []TypeRef
+	sets := make(map[string]*methodSet)
+	getSet := func(key string) *methodSet {
+		if sets[key] == nil {
+			sets[key] = &methodSet{values: make(map[string]string), pointers: make(map[string]string)}
+		}
+		return sets[key]
+	}
+
+	if err = WalkTree(searchRoot, func(filename string) error {
+		if !IsGoFile(filename) {
+			return nil
+		}
+		file, err := ParseFile(filename)
+		if err != nil {
+			return err
+		}
+		pkgPath := GetImportPath(filename)
+		for _, decl := range file.Ast.Decls {
+			switch d := decl.(type) {
+			case *ast.GenDecl:
+				for _, spec := range d.Specs {
+					if ts, ok := spec.(*ast.TypeSpec); ok {
+						if _, ok = ts.Type.(*ast.StructType); ok {
+							structs = append(structs, TypeRef{PkgPath: pkgPath, Name: ts.Name.Name, File: file})
+						}
+					}
+				}
+			case *ast.FuncDecl:
+				if d.Recv == nil || len(d.Recv.List) == 0 {
+					continue
+				}
+				name, pointer := receiverTypeName(d.Recv.List[0].Type)
+				if len(name) == 0 {
+					continue
+				}
+				set := getSet(qualifiedTypeName(pkgPath, name))
+				if pointer {
+					set.pointers[d.Name.Name] = qualifiedExprString(d.Type, file)
+				} else {
+					set.values[d.Name.Name] = qualifiedExprString(d.Type, file)
+				}
+			}
+		}
+		return nil
+	}); err != nil {
+		return
+	}
+
+	satisfies := func(sets ...map[string]string) bool {
+		for _, method := range methods {
+			found := false
+			for _, set := range sets {
+				if signature, ok := set[method.Name]; ok && signature == method.Signature {
+					found = true
+					break
+				}
+			}
+			if !found {
+				return false
+			}
+		}
+		return true
+	}
+
+	for _, ref := range structs {
+		set := getSet(qualifiedTypeName(ref.PkgPath, ref.Name))
+		if satisfies(set.values) {
+			refs = append(refs, ref)
+		} else if satisfies(set.values, set.pointers) {
+			ref.Pointer = true
+			refs = append(refs, ref)
+		}
+	}
+	return
+}
+
+// receiverTypeName returns type name of method receiver and whether receiver is pointer
+func receiverTypeName(expr ast.Expr) (name string, pointer bool) {
+	if star, ok := expr.(*ast.StarExpr); ok {
+		expr, pointer = star.X, true
+	}
+	if index, ok := expr.(*ast.IndexExpr); ok {
+		// generic receiver with single type parameter
+		expr = index.X
+	}
+	if ident, ok := expr.(*ast.Ident); ok {
+		name = ident.Name
+	}
+	return
+}
+
+// MethodSig represents renderable method signature extracted from method declaration
+type MethodSig struct {
+	Name    string
+	Docs    []string
+	Params  []Param
+	Results []Param
+
+	// Pointer represents method declared with pointer receiver
+	Pointer bool
+}
+
+// String renders method signature as interface method like "Do(ctx context.Context, v ...int) (err error)"
+func (m MethodSig) String() string {
+	list := func(params []Param) string {
+		items := make([]string, 0, len(params))
+		for _, p := range params {
+			items = append(items, strings.TrimSpace(p.Name+" "+p.Type))
+		}
+		return strings.Join(items, ", ")
+	}
+	str := m.Name + "(" + list(m.Params) + ")"
+	switch {
+	case len(m.Results) == 0:
+	case len(m.Results) == 1 && len(m.Results[0].Name) == 0:
+		str += " " + m.Results[0].Type
+	default:
+		str += " (" + list(m.Results) + ")"
+	}
+	return str
+}
+
+// ExtractInterface collects exported methods declared with receiver of structName in package directory
+// as renderable signatures in files and source order. it could be used to generate interface of struct.
+// types would be qualified for another package output with dstImports.
+// if dstImports is nil, types are kept as written for same package output
+func ExtractInterface(structName, pkgDir string, dstImports Imports) (methods []MethodSig, err error) {
+	found := false
+	_, err = WalkPackage(pkgDir, func(file *File) error {
+		if obj := file.Lookup(structName); obj != nil && obj.Kind == ast.Typ {
+			found = true
+		}
+		for _, decl := range file.Ast.Decls {
+			fd, ok := decl.(*ast.FuncDecl)
+			if !ok || fd.Recv == nil || len(fd.Recv.List) == 0 || !fd.Name.IsExported() {
+				continue
+			}
+			name, pointer := receiverTypeName(fd.Recv.List[0].Type)
+			if name != structName {
+				continue
+			}
+			method := MethodSig{Name: fd.Name.Name, Pointer: pointer}
+			if fd.Doc != nil {
+				method.Docs = strings.Split(strings.TrimSpace(fd.Doc.Text()), "\n")
+			}
+			method.Params, method.Results = FuncTypeInfo(fd.Type, file)
+			if dstImports != nil {
+				qualifyParams(file, fd.Type.Params, method.Params, dstImports)
+				qualifyParams(file, fd.Type.Results, method.Results, dstImports)
+			}
+			methods = append(methods, method)
+		}
+		return nil
+	})
+	if err == nil && !found {
+		err = fmt.Errorf("type %s not found in %s", structName, pkgDir)
+	}
+	return
+}
+
+// qualifyParams replaces params types declared in field list with package selectors of dstImports
+func qualifyParams(file *File, fl *ast.FieldList, params []Param, dstImports Imports) {
+	if fl == nil {
+		return
+	}
+	i := 0
+	for _, field := range fl.List {
+		pr := &packagesReplacer{
+			bytesReplacer: file.nodeReplacer(field.Type),
+			srcImports:    file.Imports(),
+			dstImports:    dstImports,
+			srcImportPath: GetImportPath(file.Path),
+		}
+		ast.Walk(pr, field.Type)
+		typ := string(pr.Bytes())
+
+		n := len(field.Names)
+		if n == 0 {
+			n = 1
+		}
+		for ; n > 0 && i < len(params); n-- {
+			params[i].Type = typ
+			i++
+		}
+	}
+}
+
+// qualifiedExprString formats type expression with package qualified by import path and without parameter names
+func qualifiedExprString(expr ast.Expr, file *File) string {
+	q := func(e ast.Expr) string { return qualifiedExprString(e, file) }
+	switch t := expr.(type) {
+	case *ast.Ident:
+		if types.Universe.Lookup(t.Name) != nil {
+			return t.Name
+		}
+		return qualifiedTypeName(GetImportPath(file.Path), t.Name)
+	case *ast.SelectorExpr:
+		if x, ok := t.X.(*ast.Ident); ok {
+			if p := file.Imports().Which(x.Name); len(p) > 0 {
+				return qualifiedTypeName(p, t.Sel.Name)
+			}
+		}
+	case *ast.ParenExpr:
+		return q(t.X)
+	case *ast.StarExpr:
+		return "*" + q(t.X)
+	case *ast.Ellipsis:
+		return "..." + q(t.Elt)
+	case *ast.ArrayType:
+		if t.Len == nil {
+			return "[]" + q(t.Elt)
+		}
+		return "[" + types.ExprString(t.Len) + "]" + q(t.Elt)
+	case *ast.MapType:
+		return "map[" + q(t.Key) + "]" + q(t.Value)
+	case *ast.ChanType:
+		switch t.Dir {
+		case ast.SEND:
+			return "chan<- " + q(t.Value)
+		case ast.RECV:
+			return "<-chan " + q(t.Value)
+		}
+		return "chan " + q(t.Value)
+	case *ast.FuncType:
+		list := func(fl *ast.FieldList) (ret []string) {
+			if fl == nil {
+				return
+			}
+			for _, field := range fl.List {
+				n := len(field.Names)
+				if n == 0 {
+					n = 1
+				}
+				for i := 0; i < n; i++ {
+					ret = append(ret, q(field.Type))
+				}
+			}
+			return
+		}
+		str := "func(" + strings.Join(list(t.Params), ", ") + ")"
+		switch results := list(t.Results); len(results) {
+		case 0:
+		case 1:
+			str += " " + results[0]
+		default:
+			str += " (" + strings.Join(results, ", ") + ")"
+		}
+		return str
+	}
+	return types.ExprString(expr)
+}
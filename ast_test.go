@@ -18,10 +18,16 @@
 package zcore
 
 import (
+	"bytes"
 	"go/ast"
 	"go/parser"
+	"go/token"
+	"log"
+	"os"
+	"path/filepath"
 	"reflect"
 	"strconv"
+	"strings"
 	"testing"
 )
 
@@ -50,3 +56,306 @@ func TestLookupTypSpec(t *testing.T) {
 		t.Fatal("not found")
 	}
 }
+
+func TestResolveConstraint(t *testing.T) {
+	data := []byte("package x\n\ntype Number interface{ ~int | ~int64 | float64; String() string }\n\ntype Any any\n")
+	f, err := parser.ParseFile(token.NewFileSet(), "", data, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file := &File{Path: "x.go", Data: data, Ast: f}
+
+	names, underlying := ResolveConstraint(f.Decls[0].(*ast.GenDecl).Specs[0].(*ast.TypeSpec).Type, file)
+	if strings.Join(names, ",") != "~int,~int64,float64" || strings.Join(underlying, ",") != "int,int64,float64" {
+		t.Fatal(names, underlying)
+	}
+
+	names, underlying = ResolveConstraint(f.Decls[1].(*ast.GenDecl).Specs[0].(*ast.TypeSpec).Type, file)
+	if len(names) != 1 || names[0] != "any" || len(underlying) != 0 {
+		t.Fatal(names, underlying)
+	}
+}
+
+func TestLookupTypSpecStrict(t *testing.T) {
+	StrictResolution = true
+	defer func() { StrictResolution = false }()
+	if exp, _ := LookupTypSpec("NotExist", ".", pkg); exp != nil {
+		t.Fatal(exp)
+	}
+	if err := TakeWarnings(); err == nil || !strings.Contains(err.Error(), "NotExist") {
+		t.Fatal(err)
+	}
+	if err := TakeWarnings(); err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	for name, data := range map[string]string{
+		"go.mod": "module example.com/strict\n\ngo 1.16\n",
+		"a.go":   "package strict\n\ntype A struct{}\n",
+		"b.go":   "package strict\n\ntype B struct{\n",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if exp, _ := LookupTypSpec("C", dir, "example.com/strict"); exp != nil {
+		t.Fatal(exp)
+	}
+	if err := TakeWarnings(); err == nil || !strings.Contains(err.Error(), "skipped file "+filepath.Join(dir, "b.go")) || !strings.Contains(err.Error(), "unresolved type C") {
+		t.Fatal(err)
+	}
+
+	// silent in non-strict mode
+	StrictResolution = false
+	bf := &bytes.Buffer{}
+	defer func(logger *log.Logger) { Logger = logger }(Logger)
+	Logger = log.New(bf, "", 0)
+	if exp, _ := LookupTypSpec("C", dir, "example.com/strict"); exp != nil || bf.Len() > 0 {
+		t.Fatal(exp, bf.String())
+	}
+}
+
+func TestResolveStructTree(t *testing.T) {
+	data := []byte("package x\n\ntype T struct {\n\tA int `json:\"a\"`\n\tB []*U\n\tM map[string]U\n\tN *T\n}\n\ntype U struct{ C, D string; V }\n\ntype V struct{ X Y }\n\ntype Y struct{ Z int }\n")
+	f, err := parser.ParseFile(token.NewFileSet(), "", data, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file := &File{Path: "x.go", Data: data, Ast: f}
+	decl := &AnnotatedDecl{File: file, TypeSpec: f.Decls[0].(*ast.GenDecl).Specs[0].(*ast.TypeSpec)}
+
+	tree, err := ResolveStructTree(decl, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tree.Kind != "struct" || len(tree.Fields) != 4 {
+		t.Fatal(tree)
+	}
+	if a := tree.Fields[0]; a.Name != "A" || a.Tag != `json:"a"` || a.Type.Kind != "basic" || a.Type.Type != "int" {
+		t.Fatal(a)
+	}
+	b := tree.Fields[1].Type
+	if b.Kind != "slice" || b.Type != "[]*U" || b.Elem.Kind != "pointer" || b.Elem.Elem.Kind != "struct" || len(b.Elem.Elem.Fields) != 3 {
+		t.Fatal(b)
+	}
+	if v := b.Elem.Elem.Fields[2]; !v.Embedded || v.Name != "V" || v.Type.Fields[0].Type.Fields[0].Name != "Z" {
+		t.Fatal(v)
+	}
+	if m := tree.Fields[2].Type; m.Kind != "map" || m.Key.Kind != "basic" || m.Elem.Kind != "struct" {
+		t.Fatal(m)
+	}
+	if n := tree.Fields[3].Type.Elem; n.Kind != "named" || !n.Cycle || !strings.HasSuffix(n.Named, ".T") {
+		t.Fatal(n)
+	}
+
+	// depth limited
+	if tree, err = ResolveStructTree(decl, 1); err != nil {
+		t.Fatal(err)
+	}
+	if u := tree.Fields[1].Type.Elem.Elem; u.Kind != "struct" || u.Fields[2].Type.Kind != "named" || u.Fields[2].Type.Cycle {
+		t.Fatal(u)
+	}
+
+	decl.TypeSpec = &ast.TypeSpec{Name: ast.NewIdent("I"), Type: &ast.InterfaceType{}}
+	if _, err = ResolveStructTree(decl, 0); err == nil {
+		t.Fatal()
+	}
+}
+
+func TestResolveInterfaceMethods(t *testing.T) {
+	data := []byte(`package x
+
+import ctx "context"
+
+type A interface {
+	Do(c ctx.Context, v []*T) (err error)
+	Name() string
+}
+
+type B interface {
+	Do(ctx.Context, []*T) error
+	error
+}
+
+type C interface {
+	Name() int
+}
+
+type AB interface {
+	A
+	B
+	Close() error
+}
+
+type ABC interface {
+	AB
+	C
+}
+`)
+	f, err := parser.ParseFile(token.NewFileSet(), "", data, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file := &File{Path: "x.go", Data: data, Ast: f}
+	lookup := func(name string) *ast.InterfaceType {
+		return file.Lookup(name).Decl.(*ast.TypeSpec).Type.(*ast.InterfaceType)
+	}
+
+	methods, err := ResolveInterfaceMethods(lookup("AB"), file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, method := range methods {
+		names = append(names, method.Name)
+	}
+	if strings.Join(names, ",") != "Do,Name,Error,Close" || methods[0].Signature != "func(context.Context, []*"+pkg+".T) error" {
+		t.Fatal(names, methods[0].Signature)
+	}
+
+	if _, err = ResolveInterfaceMethods(lookup("ABC"), file); err == nil || !strings.Contains(err.Error(), "Name: func() string and func() int") {
+		t.Fatal(err)
+	}
+}
+
+func TestZeroReturnStatement(t *testing.T) {
+	results := []Param{
+		{Type: "int"}, {Type: "string"}, {Type: "error"}, {Type: "*T"}, {Type: "[]byte"}, {Type: "[2]int"},
+		{Type: "map[string]int"}, {Type: "struct{}"}, {Type: "time.Duration"}, {Type: "errs.Code"}, {Type: "T"},
+	}
+	dstImports := Imports{"time": "time", "host.com/errs": "."}
+	want := `return 0, "", nil, nil, nil, [2]int{}, nil, struct{}{}, *new(time.Duration), *new(Code), *new(T)`
+	if ret := ZeroReturnStatement(results, dstImports); ret != want {
+		t.Fatal(ret)
+	}
+	if ret := ZeroReturnStatement([]Param{{Name: "n", Type: "int"}, {Name: "err", Type: "error"}}, dstImports); ret != "return" {
+		t.Fatal(ret)
+	}
+	if ret := ZeroReturnStatement(nil, dstImports); ret != "return" {
+		t.Fatal(ret)
+	}
+	if ret := StubStatement(results, dstImports, true); ret != `panic("not implemented")` {
+		t.Fatal(ret)
+	}
+}
+
+func TestFindImplementations(t *testing.T) {
+	dir := t.TempDir()
+	for name, data := range map[string]string{
+		"go.mod": "module example.com/di\n\ngo 1.16\n",
+		"svc/svc.go": `package svc
+
+import "context"
+
+type Service interface {
+	Do(ctx context.Context, v *Value) error
+}
+
+type Value struct{}
+`,
+		"impl/impl.go": `package impl
+
+import (
+	"context"
+
+	"example.com/di/svc"
+)
+
+type A struct{}
+
+func (A) Do(context.Context, *svc.Value) error { return nil }
+
+type B struct{}
+
+func (*B) Do(c context.Context, v *svc.Value) (err error) { return nil }
+
+type C struct{}
+
+func (C) Do(context.Context, svc.Value) error { return nil }
+
+type D struct{}
+`,
+	} {
+		filename := filepath.Join(dir, filepath.FromSlash(name))
+		_ = os.MkdirAll(filepath.Dir(filename), 0o755)
+		if err := os.WriteFile(filename, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	refs, err := FindImplementations("example.com/di/svc", "Service", dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(refs) != 2 || refs[0].Name != "A" || refs[0].Pointer || refs[1].Name != "B" || !refs[1].Pointer || refs[0].PkgPath != "example.com/di/impl" {
+		t.Fatal(refs)
+	}
+	if _, err = FindImplementations("example.com/di/svc", "Value", dir); err == nil {
+		t.Fatal("not interface")
+	}
+}
+
+func TestExtractInterface(t *testing.T) {
+	dir := t.TempDir()
+	for name, data := range map[string]string{
+		"go.mod": "module example.com/api\n\ngo 1.16\n",
+		"svc/a.go": `package svc
+
+import ctx "context"
+
+type Service struct{}
+
+// Get gets value
+func (s *Service) Get(c ctx.Context, id int) (v *Value, err error) { return }
+
+func (Service) List(ids ...int) []Value { return nil }
+
+func (s *Service) internal() {}
+
+type Value struct{}
+`,
+		"svc/b.go": `package svc
+
+func (s *Service) Close() error { return nil }
+
+func (v Value) Other() {}
+`,
+	} {
+		filename := filepath.Join(dir, filepath.FromSlash(name))
+		_ = os.MkdirAll(filepath.Dir(filename), 0o755)
+		if err := os.WriteFile(filename, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	render := func(methods []MethodSig) (ret []string) {
+		for _, method := range methods {
+			ret = append(ret, method.String())
+		}
+		return
+	}
+
+	methods, err := ExtractInterface("Service", filepath.Join(dir, "svc"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ret := strings.Join(render(methods), "|"); ret != "Get(c ctx.Context, id int) (v *Value, err error)|List(ids ...int) []Value|Close() error" {
+		t.Fatal(ret)
+	}
+	if !methods[0].Pointer || methods[1].Pointer || methods[0].Docs[0] != "Get gets value" {
+		t.Fatal(methods)
+	}
+
+	imports := Imports{}
+	if methods, err = ExtractInterface("Service", filepath.Join(dir, "svc"), imports); err != nil {
+		t.Fatal(err)
+	}
+	if ret := strings.Join(render(methods), "|"); ret != "Get(c context.Context, id int) (v *svc.Value, err error)|List(ids ...int) []svc.Value|Close() error" {
+		t.Fatal(ret, imports)
+	}
+
+	if _, err = ExtractInterface("None", filepath.Join(dir, "svc"), nil); err == nil {
+		t.Fatal("not found")
+	}
+}
@@ -0,0 +1,188 @@
+/*
+ * Copyright (c) 2023 Maple Wu <justmaplewu@gmail.com>
+ *   National Electronics and Computer Technology Center, Thailand
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package zcore
+
+import (
+	"encoding/json"
+	"os"
+	"sync"
+)
+
+// CacheFilename is default filename of persisted module resolution caches
+const CacheFilename = "." + ExecName + "cache"
+
+// cacheStores provides named module resolution caches which could be persisted as cache file.
+// cache file is json object with cache name as key and key-value map of cache as value
+var cacheStores = map[string]*sync.Map{
+	"import_name":         importNameCache,
+	"import_path":         importPathCache,
+	"import_package_name": importPackageNameCache,
+	"import_package_dir":  importPackageDirCache,
+	"mod_file":            modFileCache,
+	"entity_hash":         entityHashCache,
+}
+
+// entityHashCache stores DeclEntity input hashes with key provided by plugin
+var entityHashCache = new(sync.Map)
+
+type (
+	// CacheStore represents persistence backend of module resolution caches
+	// caches are key-value maps with cache name as key
+	CacheStore interface {
+		Load() (caches map[string]map[string]string, err error)
+		Save(caches map[string]map[string]string) (err error)
+	}
+
+	// FileCacheStore implements CacheStore as json file with filename
+	FileCacheStore string
+
+	// MemoryCacheStore implements CacheStore in memory
+	MemoryCacheStore struct {
+		mu     sync.Mutex
+		caches map[string]map[string]string
+	}
+)
+
+var (
+	cacheStore   CacheStore = FileCacheStore(CacheFilename)
+	cacheStoreMu sync.Mutex
+)
+
+// SetCacheStore replaces default CacheStore used by LoadCacheStore and SaveCacheStore
+func SetCacheStore(store CacheStore) {
+	cacheStoreMu.Lock()
+	defer cacheStoreMu.Unlock()
+	cacheStore = store
+}
+
+func getCacheStore() CacheStore {
+	cacheStoreMu.Lock()
+	defer cacheStoreMu.Unlock()
+	return cacheStore
+}
+
+// LoadCacheStore loads caches from CacheStore and merges into in-memory caches
+func LoadCacheStore() (err error) {
+	caches, err := getCacheStore().Load()
+	if err != nil {
+		return
+	}
+	loadCaches(caches)
+	return
+}
+
+// SaveCacheStore dumps all in-memory caches and saves into CacheStore
+func SaveCacheStore() error { return getCacheStore().Save(dumpCaches()) }
+
+// Load reads and decodes cache file. not exist file would be loaded as empty
+func (filename FileCacheStore) Load() (caches map[string]map[string]string, err error) {
+	data, _, err := ReadFile(string(filename))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return
+	}
+	err = json.Unmarshal(data, &caches)
+	return
+}
+
+// Save encodes and writes caches into cache file
+func (filename FileCacheStore) Save(caches map[string]map[string]string) (err error) {
+	data, err := json.MarshalIndent(caches, "", "  ")
+	if err != nil {
+		return
+	}
+	_, err = WriteFile(string(filename), data, 0o664)
+	return
+}
+
+// Load returns copy of saved caches
+func (store *MemoryCacheStore) Load() (map[string]map[string]string, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	return copyCaches(store.caches), nil
+}
+
+// Save stores copy of caches
+func (store *MemoryCacheStore) Save(caches map[string]map[string]string) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	store.caches = copyCaches(caches)
+	return nil
+}
+
+func copyCaches(caches map[string]map[string]string) map[string]map[string]string {
+	ret := make(map[string]map[string]string, len(caches))
+	for name, kvs := range caches {
+		m := make(map[string]string, len(kvs))
+		for k, v := range kvs {
+			m[k] = v
+		}
+		ret[name] = m
+	}
+	return ret
+}
+
+// MergeCacheStore loads cache files and merges into in-memory caches in order.
+// value of same key in later file would overwrite previous. not exist files would be skipped
+func MergeCacheStore(paths ...string) (err error) {
+	for _, path := range paths {
+		caches, err := FileCacheStore(path).Load()
+		if err != nil {
+			return err
+		}
+		loadCaches(caches)
+	}
+	return
+}
+
+// FlushCacheStoreTo dumps all in-memory caches and writes into cache file path
+func FlushCacheStoreTo(path string) (err error) {
+	return FileCacheStore(path).Save(dumpCaches())
+}
+
+// loadCaches stores key-values into named caches
+func loadCaches(caches map[string]map[string]string) {
+	for name, kvs := range caches {
+		store, ok := cacheStores[name]
+		if !ok {
+			continue
+		}
+		for k, v := range kvs {
+			store.Store(k, v)
+		}
+	}
+}
+
+// dumpCaches returns key-values of all named caches
+func dumpCaches() (caches map[string]map[string]string) {
+	caches = make(map[string]map[string]string, len(cacheStores))
+	for name, store := range cacheStores {
+		kvs := make(map[string]string)
+		store.Range(func(key, value interface{}) bool {
+			k, ok1 := key.(string)
+			v, ok2 := value.(string)
+			if ok1 && ok2 {
+				kvs[k] = v
+			}
+			return true
+		})
+		caches[name] = kvs
+	}
+	return
+}
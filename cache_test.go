@@ -0,0 +1,82 @@
+/*
+ * Copyright (c) 2023 Maple Wu <justmaplewu@gmail.com>
+ *   National Electronics and Computer Technology Center, Thailand
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package zcore
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMergeCacheStore(t *testing.T) {
+	dir := t.TempDir()
+	a, b := filepath.Join(dir, "a"+CacheFilename), filepath.Join(dir, "b"+CacheFilename)
+	if err := os.WriteFile(a, []byte(`{"mod_file":{"/cache/x":"/x/go.mod","/cache/y":"/y/go.mod"}}`), 0o664); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(b, []byte(`{"mod_file":{"/cache/y":"/z/go.mod"},"unknown":{"k":"v"}}`), 0o664); err != nil {
+		t.Fatal(err)
+	}
+	defer modFileCache.Delete("/cache/x")
+	defer modFileCache.Delete("/cache/y")
+
+	if err := MergeCacheStore(a, b, filepath.Join(dir, "not_exist")); err != nil {
+		t.Fatal(err)
+	}
+	if v := GetModFile("/cache/x"); v != "/x/go.mod" {
+		t.Fatal(v)
+	}
+	if v := GetModFile("/cache/y"); v != "/z/go.mod" {
+		t.Fatal(v)
+	}
+
+	c := filepath.Join(dir, "c"+CacheFilename)
+	if err := FlushCacheStoreTo(c); err != nil {
+		t.Fatal(err)
+	}
+	modFileCache.Delete("/cache/y")
+	if err := MergeCacheStore(c); err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := modFileCache.Load("/cache/y"); v != "/z/go.mod" {
+		t.Fatal(v)
+	}
+}
+
+func TestSetCacheStore(t *testing.T) {
+	store := &MemoryCacheStore{}
+	SetCacheStore(store)
+	defer SetCacheStore(FileCacheStore(CacheFilename))
+
+	modFileCache.Store("/memory/x", "/x/go.mod")
+	if err := SaveCacheStore(); err != nil {
+		t.Fatal(err)
+	}
+	modFileCache.Delete("/memory/x")
+	defer modFileCache.Delete("/memory/x")
+
+	if caches, _ := store.Load(); caches["mod_file"]["/memory/x"] != "/x/go.mod" {
+		t.Fatal(caches)
+	}
+	if err := LoadCacheStore(); err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := modFileCache.Load("/memory/x"); v != "/x/go.mod" {
+		t.Fatal(v)
+	}
+}
@@ -18,19 +18,42 @@
 package zcore
 
 import (
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
 )
 
 type (
-	// DeclEntity represents annotated ast.Decl with parsed args and options
+	// DeclEntity represents annotated ast.Decl with parsed args and options.
+	// it is the standard template data of declaration and provides stable fields for template authors:
+	//
+	//	.Name      declaration name
+	//	.Package   declaration package name
+	//	.Filename  declaration base filename
+	//	.Type      declaration type, see DeclTypeString
+	//	.Docs      declaration docs lines
+	//	.Fields    annotated fields of struct or interface
+	//	.Plugin    plugin name of annotation
+	//	.Args      parsed annotation args
+	//	.Options   parsed annotation options, use like {{ .Options.Get "key" "default" }}
 	DeclEntity struct {
 		*AnnotatedDecl
 
 		Plugin  string
 		Args    []string
 		Options Options
+
+		// Raw is annotation text without prefix as written in source before escape processing
+		Raw string
+
+		// Priority is parsed from optional suffix of annotation name like "foo@10". default is 0
+		Priority int
 	}
 
 	DeclEntities []DeclEntity
@@ -41,18 +64,36 @@ type (
 
 		Args    []string
 		Options Options
+
+		// Raw is annotation text without prefix as written in source before escape processing
+		Raw string
 	}
 
 	FieldEntities []FieldEntity
 
+	// ConstMember represents constant member declared with annotated type in const block like enum values.
+	// member annotations matched with entity plugin would be parsed as Options
+	ConstMember struct {
+		Name      string
+		ValueSpec *ast.ValueSpec
+		Docs      []string
+		Options   Options
+
+		// Annotations are raw member annotations matched with entity plugin
+		Annotations []string
+	}
+
+	ConstMembers []ConstMember
+
 	// Options carries parsed key-value options from annotations
 	Options map[string]string
 )
 
 const (
-	AnnotationSeparator       = ":"
-	EscapeAnnotationSeparator = `\u003A`
-	KeyValueSeparator         = "="
+	AnnotationPrioritySeparator = "@"
+	AnnotationSeparator         = ":"
+	EscapeAnnotationSeparator   = `\u003A`
+	KeyValueSeparator           = "="
 )
 
 // Get option value by key from Options map. if empty return default value from def
@@ -63,6 +104,38 @@ func (opt Options) Get(key string, def string) string {
 	return def
 }
 
+// Merge returns new Options contains key-values of both options.
+// if overwrite is true, value of other options would win on same key or else keep value of opt.
+// for example layering as annotation options over command options:
+//
+//	annotationOptions.Merge(commandOptions, false)
+func (opt Options) Merge(other Options, overwrite bool) Options {
+	ret := make(Options, len(opt)+len(other))
+	for k, v := range opt {
+		ret[k] = v
+	}
+	for k, v := range other {
+		if _, exist := ret[k]; exist && !overwrite {
+			continue
+		}
+		ret[k] = v
+	}
+	return ret
+}
+
+// JSON unmarshals json value of key such as "config={"a":1,"b":[2,3]}" into v.
+// v would not be modified if key not exist or value is empty
+func (opt Options) JSON(key string, v interface{}) error {
+	value := opt[key]
+	if len(value) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal([]byte(value), v); err != nil {
+		return fmt.Errorf("invalid json option %q: %w", key, err)
+	}
+	return nil
+}
+
 // Exist checks key in Options map. if exist and not empty then return strconv.ParseBool result
 func (opt Options) Exist(key string) bool {
 	if v, ok := opt[key]; ok {
@@ -72,6 +145,45 @@ func (opt Options) Exist(key string) bool {
 	return false
 }
 
+// IndexedSlice collects options with indexed keys "$key.$index" and returns values sorted by index.
+// values of indexed keys would never be joined with others, so it could contain ","
+//
+// for example
+//
+//	// +zz:foo:item.0=a:item.1=b,c:item.2=d
+//
+// Options.IndexedSlice("item") returns [a b,c d]
+func (opt Options) IndexedSlice(key string) (values []string) {
+	type item struct {
+		index int
+		value string
+	}
+
+	items := make([]item, 0)
+	for k, v := range opt {
+		str, ok := TrimPrefix(k, key+".")
+		if !ok {
+			continue
+		}
+		if index, err := strconv.Atoi(str); err == nil {
+			items = append(items, item{index: index, value: v})
+		}
+	}
+
+	sort.Slice(items, func(i, j int) bool { return items[i].index < items[j].index })
+
+	for _, it := range items {
+		values = append(values, it.value)
+	}
+	return
+}
+
+// ParseAnnotation parse annotation string without prefix with same rules as plugins annotations parsing.
+// see parseAnnotation for annotation format
+func ParseAnnotation(annotation, name string, argsCount int, extOptions map[string]string) (args []string, options Options, ok bool) {
+	return parseAnnotation(annotation, name, argsCount, extOptions)
+}
+
 // parseAnnotation parse annotation string
 // annotation strings would split by ":" and check first matches provided name
 // if not matched then return ok=false
@@ -81,23 +193,44 @@ func (opt Options) Exist(key string) bool {
 //
 // annotation format  $name:$args1:$args2:...$argsN:$key1=$value1:$key2=$value2:...
 //
+// value of balanced braces like json object $key={"a":1} would not be split. see Options.JSON
+//
 // for example
 //
 // params:
-// 	 annotation  foo:args1:args2:key1=value1:key2=value2
-//   name        foo
-//   argsCount   2
-//   extOptions  [key3:value3 key4:value4]
+//
+//	annotation  foo:args1:args2:key1=value1:key2=value2
+//	name        foo
+//	argsCount   2
+//	extOptions  [key3:value3 key4:value4]
 //
 // returns:
-// 	 args        [args1 args2]
-//   options     [key1:value1 key2:value2 key3:value3 key4:value4]
-//   ok          true
+//
+//	args        [args1 args2]
+//	options     [key1:value1 key2:value2 key3:value3 key4:value4]
+//	ok          true
 func parseAnnotation(annotation, name string, argsCount int, extOptions map[string]string) (args []string, options map[string]string, ok bool) {
-	sp := strings.Split(EscapeAnnotation(annotation), AnnotationSeparator)
-	if sp[0] != name || len(sp)-1 < argsCount {
+	return parseAnnotationArity(annotation, name, argsCount, argsCount, extOptions)
+}
+
+// parseAnnotationArity parses annotation as parseAnnotation with args count range.
+// at least minArgs args are required. following elements without KeyValueSeparator
+// would be taken as optional args until maxArgs, negative maxArgs means no limit
+func parseAnnotationArity(annotation, name string, minArgs, maxArgs int, extOptions map[string]string) (args []string, options map[string]string, ok bool) {
+	sp := splitAnnotation(EscapeAnnotation(annotation))
+	if n, _, valid := splitAnnotationPriority(sp[0]); !valid || n != name || len(sp)-1 < minArgs {
 		return
 	}
+
+	if err := ValidateAnnotationEscapes(annotation); err != nil {
+		Warnf("%v", err)
+	}
+
+	argsCount := minArgs
+	for argsCount < len(sp)-1 && (maxArgs < 0 || argsCount < maxArgs) && !strings.Contains(sp[1+argsCount], KeyValueSeparator) {
+		argsCount++
+	}
+
 	options = make(map[string]string)
 	SplitKVSlice2Map(sp[1+argsCount:], KeyValueSeparator, options)
 
@@ -105,21 +238,119 @@ func parseAnnotation(annotation, name string, argsCount int, extOptions map[stri
 		options[k] = UnescapeAnnotation(v)
 	}
 
-	for k, v := range extOptions {
-		if _, exist := options[k]; exist {
-			continue
+	args = sp[1 : 1+argsCount]
+	for i, arg := range args {
+		args[i] = UnescapeAnnotation(arg)
+	}
+
+	return args, Options(options).Merge(extOptions, false), true
+}
+
+// splitAnnotation splits escaped annotation by AnnotationSeparator.
+// balanced braces like json object value "config={"a":1}" would be kept as one element
+// and separators inside json strings of braces are ignored. unbalanced braces are split as plain text
+func splitAnnotation(str string) []string {
+	var (
+		sp     []string
+		start  int
+		depth  int
+		quoted bool
+	)
+	for i := 0; i < len(str); i++ {
+		switch c := str[i]; {
+		case quoted:
+			if c == '\\' {
+				i++
+			} else if c == '"' {
+				quoted = false
+			}
+		case c == '"' && depth > 0:
+			quoted = true
+		case c == '{':
+			depth++
+		case c == '}' && depth > 0:
+			depth--
+		case depth == 0 && strings.HasPrefix(str[i:], AnnotationSeparator):
+			sp = append(sp, str[start:i])
+			start = i + len(AnnotationSeparator)
 		}
-		options[k] = v
 	}
-	return sp[1 : 1+argsCount], options, true
+	if depth > 0 || quoted {
+		return strings.Split(str, AnnotationSeparator)
+	}
+	return append(sp, str[start:])
+}
+
+// splitAnnotationPriority split annotation name and optional priority suffix like "foo@10"
+// returns valid=false if priority suffix is not integer
+func splitAnnotationPriority(str string) (name string, priority int, valid bool) {
+	name, p := SplitKV(str, AnnotationPrioritySeparator)
+	if !strings.Contains(str, AnnotationPrioritySeparator) {
+		return name, 0, true
+	}
+	priority, err := strconv.Atoi(p)
+	return name, priority, err == nil
+}
+
+// AnnotationName returns plugin name of annotation without prefix
+func AnnotationName(annotation string) (name string) {
+	name, _, _ = splitAnnotationPriority(strings.SplitN(annotation, AnnotationSeparator, 2)[0])
+	return
+}
+
+// annotationPriority returns priority suffix of annotation name or 0 if not provided
+func annotationPriority(annotation string) (priority int) {
+	_, priority, _ = splitAnnotationPriority(strings.SplitN(annotation, AnnotationSeparator, 2)[0])
+	return
 }
 
-func EscapeAnnotation(str string) string {
-	return strings.Replace(str, `\:`, EscapeAnnotationSeparator, -1)
+var (
+	// annotationEscaper escapes "\:" and also escapes literal "\u" in user text as "\u005Cu"
+	// so literal escape sequence in user text would never be unescaped as separator
+	annotationEscaper = strings.NewReplacer(`\:`, EscapeAnnotationSeparator, `\u`, `\u005Cu`)
+	// annotationUnescaper reverts annotationEscaper in single pass
+	annotationUnescaper = strings.NewReplacer(EscapeAnnotationSeparator, AnnotationSeparator, `\u005Cu`, `\u`)
+)
+
+// ValidateAnnotationEscapes reports backslash escapes in annotation which would not be interpreted.
+//
+// escape grammar of annotation:
+//
+//	\:    literal ":" which would not be taken as separator
+//	\\    kept as written, no escape
+//
+// any other backslash is kept as written. sequences like "\n" "\t" are reported
+// since they are commonly expected to be interpreted as control characters
+func ValidateAnnotationEscapes(annotation string) error {
+	var unknown []string
+	for i := 0; i < len(annotation)-1; i++ {
+		if annotation[i] != '\\' {
+			continue
+		}
+		switch annotation[i+1] {
+		case ':', '\\':
+			i++
+		case 'a', 'b', 'f', 'n', 'r', 't', 'v':
+			unknown = append(unknown, annotation[i:i+2])
+			i++
+		}
+	}
+	if len(unknown) > 0 {
+		return fmt.Errorf("annotation %q contains uninterpreted escapes: %s", annotation, strings.Join(unknown, " "))
+	}
+	return nil
 }
 
-func UnescapeAnnotation(str string) string {
-	return strings.Replace(str, EscapeAnnotationSeparator, AnnotationSeparator, -1)
+// EscapeAnnotation escapes "\:" in annotation as EscapeAnnotationSeparator before splitting by AnnotationSeparator
+func EscapeAnnotation(str string) string { return annotationEscaper.Replace(str) }
+
+// UnescapeAnnotation reverts escaped annotation text from EscapeAnnotation
+func UnescapeAnnotation(str string) string { return annotationUnescaper.Replace(str) }
+
+// SortByPriority sorts entities by annotation priority in ascending order.
+// entities with same priority would keep source order
+func (entities DeclEntities) SortByPriority() {
+	sort.SliceStable(entities, func(i, j int) bool { return entities[i].Priority < entities[j].Priority })
 }
 
 // GroupByDir groups entities into string map by declaration file dir
@@ -140,10 +371,243 @@ func (entities DeclEntities) GroupBy(fn func(entity DeclEntity) string) (m map[s
 	return
 }
 
-// ParseFields parses decl fields annotation and returns FieldEntities
+// CheckOutputConflicts checks entities which fn returns same output filename.
+// it helps generators which output one file per entity to detect non-unique templated filenames.
+// returns error listing conflicted filename and entities
+func (entities DeclEntities) CheckOutputConflicts(fn func(entity DeclEntity) string) error {
+	groups := entities.GroupBy(fn)
+
+	filenames := make([]string, 0)
+	for filename, group := range groups {
+		if len(group) > 1 {
+			filenames = append(filenames, filename)
+		}
+	}
+	if len(filenames) == 0 {
+		return nil
+	}
+	sort.Strings(filenames)
+
+	lines := make([]string, 0, len(filenames))
+	for _, filename := range filenames {
+		names := make([]string, 0, len(groups[filename]))
+		for _, entity := range groups[filename] {
+			names = append(names, fmt.Sprintf("%s:%s", entity.File.Path, entity.Name()))
+		}
+		Appendf(&lines, "%s <- %s", filename, strings.Join(names, ", "))
+	}
+	return fmt.Errorf("conflicted output files:\n%s", strings.Join(lines, "\n"))
+}
+
+// InputHash computes stable hash of entity generation inputs consists of plugin name, args, options, docs,
+// declaration source, file imports and resolved source of non-standard types referenced by declaration recursively.
+// unrelated code changes in same file would not change hash
+func (entity *DeclEntity) InputHash() string {
+	bf := &strings.Builder{}
+	_, _ = fmt.Fprintf(bf, "%s\n%s\n%d\n", entity.Plugin, entity.Name(), entity.Type)
+	for _, arg := range entity.Args {
+		_, _ = fmt.Fprintf(bf, "arg:%q\n", arg)
+	}
+	for _, doc := range entity.Docs {
+		_, _ = fmt.Fprintf(bf, "doc:%q\n", doc)
+	}
+	keys := make([]string, 0, len(entity.Options))
+	for key := range entity.Options {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		_, _ = fmt.Fprintf(bf, "option:%q=%q\n", key, entity.Options[key])
+	}
+
+	if entity.File != nil && entity.File.Ast != nil {
+		imports := make([]string, 0, len(entity.File.Ast.Imports))
+		for _, imp := range entity.File.Ast.Imports {
+			imports = append(imports, string(entity.File.Node(imp)))
+		}
+		sort.Strings(imports)
+		for _, imp := range imports {
+			_, _ = fmt.Fprintf(bf, "import:%s\n", imp)
+		}
+
+		var (
+			node  ast.Node
+			exprs []ast.Expr
+		)
+		switch {
+		case entity.TypeSpec != nil:
+			node, exprs = entity.TypeSpec, []ast.Expr{entity.TypeSpec.Type}
+		case entity.FuncDecl != nil:
+			node, exprs = entity.FuncDecl, []ast.Expr{entity.FuncDecl.Type}
+			if entity.FuncDecl.Recv != nil {
+				for _, field := range entity.FuncDecl.Recv.List {
+					exprs = append(exprs, field.Type)
+				}
+			}
+		case entity.ValueSpec != nil:
+			node = entity.ValueSpec
+			if entity.ValueSpec.Type != nil {
+				exprs = []ast.Expr{entity.ValueSpec.Type}
+			}
+		}
+		if node != nil {
+			_, _ = fmt.Fprintf(bf, "decl:%s\n", entity.File.Node(node))
+		}
+
+		visited := map[string]bool{qualifiedTypeName(GetImportPath(entity.File.Path), entity.Name()): true}
+		for _, expr := range exprs {
+			writeTypeRefs(bf, expr, entity.File, visited)
+		}
+	}
+	return contentChecksum([]byte(bf.String()))
+}
+
+// writeTypeRefs writes source of non-standard named types referenced in type expression into builder recursively.
+// visited types would be written once in order of references
+func writeTypeRefs(bf *strings.Builder, expr ast.Expr, file *File, visited map[string]bool) {
+	ast.Inspect(expr, func(node ast.Node) bool {
+		var (
+			name, pkgPath string
+			local         *File
+		)
+		switch n := node.(type) {
+		case *ast.Field:
+			// skip field names
+			writeTypeRefs(bf, n.Type, file, visited)
+			return false
+		case *ast.SelectorExpr:
+			x, ok := n.X.(*ast.Ident)
+			if !ok {
+				return false
+			}
+			name, pkgPath = n.Sel.Name, file.Imports().Which(x.Name)
+		case *ast.Ident:
+			if types.Universe.Lookup(n.Name) != nil {
+				return false
+			}
+			name, pkgPath, local = n.Name, GetImportPath(file.Path), file
+		default:
+			return true
+		}
+
+		if len(pkgPath) == 0 || IsStandardImportPath(pkgPath) {
+			return false
+		}
+		named := qualifiedTypeName(pkgPath, name)
+		if visited[named] {
+			return false
+		}
+		visited[named] = true
+
+		spec, srcFile := lookupTypeSpecDecl(name, pkgPath, filepath.Dir(file.Path), local)
+		if spec == nil {
+			_, _ = fmt.Fprintf(bf, "ref:%s:unresolved\n", named)
+			return false
+		}
+		_, _ = fmt.Fprintf(bf, "ref:%s:%s\n", named, srcFile.Node(spec))
+		writeTypeRefs(bf, spec.Type, srcFile, visited)
+		return false
+	})
+}
+
+// InputUnchanged checks stored input hash of key equals to entity InputHash.
+// key is usually output filename of entity. hashes are persisted with cache file by SaveCacheStore
+// so plugin could skip regeneration when inputs are unchanged
+func (entity *DeclEntity) InputUnchanged(key string) bool {
+	v, ok := entityHashCache.Load(key)
+	return ok && v == entity.InputHash()
+}
+
+// SaveInputHash stores entity InputHash with key. it should be called after generation succeed
+func (entity *DeclEntity) SaveInputHash(key string) {
+	entityHashCache.Store(key, entity.InputHash())
+}
+
+// ParseFields parses decl fields annotation and returns FieldEntities.
+// entities are ordered by fields source order and then annotations source order of each field
+// so generators could rely on it for deterministic output
 func (entity *DeclEntity) ParseFields(argsCount int, options map[string]string) (fields FieldEntities) {
 	for _, field := range entity.Fields {
 		fields = append(fields, field.Parse(entity.Plugin, argsCount, options)...)
 	}
 	return
 }
+
+// ConstMembers collects constants declared with entity type in package of entity file as source order.
+// constants in const block without type and values would inherit previous type like iota enums.
+// member annotations are parsed with AnnotationPrefix and merged as options, later value overwrites previous.
+// annotated members could be omitted from DeclValue entities of plugin by ParseConfig.OmitConstMembers
+//
+// Example:
+//
+//	// +zz:enum
+//	type Status int
+//
+//	const (
+//		// +zz:enum:label=Active
+//		StatusActive Status = iota
+//		// +zz:enum:label=Inactive
+//		StatusInactive
+//	)
+func (entity *DeclEntity) ConstMembers() (members ConstMembers) {
+	if entity.TypeSpec == nil {
+		return
+	}
+
+	name := entity.Name()
+	_, _ = WalkPackage(filepath.Dir(entity.File.Path), func(file *File) error {
+		if file.Ast.Name.Name != entity.Package() {
+			return nil
+		}
+		for _, decl := range file.Ast.Decls {
+			gen, ok := decl.(*ast.GenDecl)
+			if !ok || gen.Tok != token.CONST {
+				continue
+			}
+			var typ ast.Expr
+			for _, spec := range gen.Specs {
+				vs, ok := spec.(*ast.ValueSpec)
+				if !ok {
+					continue
+				}
+				// implicit repetition of previous type and values
+				if vs.Type != nil || len(vs.Values) > 0 {
+					typ = vs.Type
+				}
+				if ident, ok := typ.(*ast.Ident); !ok || ident.Name != name {
+					continue
+				}
+				members = append(members, entity.parseConstMembers(vs)...)
+			}
+		}
+		return nil
+	})
+	return
+}
+
+func (entity *DeclEntity) parseConstMembers(vs *ast.ValueSpec) (members ConstMembers) {
+	docs, annotations := ParseCommentGroup(AnnotationPrefix, vs.Doc, vs.Comment)
+	options := make(Options)
+	matched := make([]string, 0)
+	for _, annotation := range annotations {
+		if _, opt, ok := parseAnnotation(annotation, entity.Plugin, 0, nil); ok {
+			for k, v := range opt {
+				options[k] = v
+			}
+			matched = append(matched, annotation)
+		}
+	}
+	for _, ident := range vs.Names {
+		if ident.Name == "_" {
+			continue
+		}
+		members = append(members, ConstMember{
+			Name:        ident.Name,
+			ValueSpec:   vs,
+			Docs:        docs,
+			Options:     options,
+			Annotations: matched,
+		})
+	}
+	return
+}
@@ -18,6 +18,11 @@
 package zcore
 
 import (
+	"go/ast"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
 	"testing"
 )
 
@@ -28,3 +33,347 @@ func TestParseAnnotation(t *testing.T) {
 		t.Fatal(args, opt, ok)
 	}
 }
+
+func TestOptionsIndexedSlice(t *testing.T) {
+	_, opt, _ := parseAnnotation(`test:item.1=b,c:item.0=a:item.10=e:item.2=d:item.x=f:items=g`, "test", 0, nil)
+	if v := Options(opt).IndexedSlice("item"); len(v) != 4 || v[0] != "a" || v[1] != "b,c" || v[2] != "d" || v[3] != "e" {
+		t.Fatal(v)
+	}
+}
+
+func TestDeclEntitiesSortByPriority(t *testing.T) {
+	decl := &AnnotatedDecl{Annotations: []string{"test@20:c", "test:a", "test@-1:x", "test@10:b", "test@x:y", "test:d"}}
+	entities := decl.parse("test", 1, nil)
+	entities.SortByPriority()
+	args := make([]string, 0)
+	for _, entity := range entities {
+		args = append(args, entity.Args[0])
+	}
+	if strings.Join(args, ",") != "x,a,d,b,c" {
+		t.Fatal(args)
+	}
+}
+
+func TestExportedParseAnnotation(t *testing.T) {
+	if args, opt, ok := ParseAnnotation(`test:arg0:k1=\:v1`, "test", 1, nil); !ok || len(args) != 1 || opt.Get("k1", "") != ":v1" {
+		t.Fatal(args, opt, ok)
+	}
+	if _, _, ok := ParseAnnotation(`other:arg0`, "test", 1, nil); ok {
+		t.Fatal(ok)
+	}
+}
+
+func TestEscapeAnnotation(t *testing.T) {
+	for _, str := range []string{`a`, `:`, `\u003A`, `\`, `x\u`, `\`, `\\`, `u003A\`} {
+		if ret := UnescapeAnnotation(EscapeAnnotation(str)); ret != str {
+			t.Fatal(str, ret)
+		}
+	}
+
+	args, opt, ok := parseAnnotation(`test:http\://a\:b:url=http\://host/?a=b:lit=\::mix=\\\:\u003A`, "test", 1, nil)
+	if !ok || args[0] != `http://a:b` || opt["url"] != "http://host/?a=b" || opt["lit"] != `:` || opt["mix"] != `\\:\u003A` {
+		t.Fatal(args, opt, ok)
+	}
+}
+
+func TestCheckOutputConflicts(t *testing.T) {
+	file := &File{Path: "x.go"}
+	newEntity := func(name string) DeclEntity {
+		return DeclEntity{AnnotatedDecl: &AnnotatedDecl{File: file, TypeSpec: &ast.TypeSpec{Name: ast.NewIdent(name)}}}
+	}
+	entities := DeclEntities{newEntity("A"), newEntity("B"), newEntity("AB")}
+	if err := entities.CheckOutputConflicts(func(entity DeclEntity) string { return entity.Name() + ".go" }); err != nil {
+		t.Fatal(err)
+	}
+	err := entities.CheckOutputConflicts(func(entity DeclEntity) string { return entity.Name()[:1] + ".go" })
+	if err == nil || !strings.Contains(err.Error(), "A.go <- x.go:A, x.go:AB") {
+		t.Fatal(err)
+	}
+}
+
+func TestParseFieldsOrder(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "x.go")
+	if err := os.WriteFile(filename, []byte(`package x
+
+// +zz:test
+type T struct {
+	// +zz:test:a1
+	// +zz:test:a2
+	A int
+	B int
+	// +zz:test:c1
+	C, D int
+	// +zz:test:e1
+	// +zz:other:x
+	// +zz:test:e2
+	E int
+}
+`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	decls, err := ParseFileDecls(filename, AnnotationPrefix)
+	if err != nil || len(decls) != 1 {
+		t.Fatal(decls, err)
+	}
+	entities := decls[0].parse("test", 0, nil)
+	if len(entities) != 1 {
+		t.Fatal(entities)
+	}
+	var ret []string
+	for _, field := range entities[0].ParseFields(1, nil) {
+		ret = append(ret, field.Args[0])
+	}
+	if strings.Join(ret, ",") != "a1,a2,c1,e1,e2" {
+		t.Fatal(ret)
+	}
+}
+
+func TestParseAnnotationArity(t *testing.T) {
+	for annotation, want := range map[string]string{
+		`test:a`:             "",
+		`test:a:b`:           "a,b",
+		`test:a:b:c`:         "a,b,c",
+		`test:a:b:c:d`:       "a,b,c,d",
+		`test:a:b:c:d:e`:     "a,b,c,d|e",
+		`test:a:b:k=v:c`:     "a,b|c,k",
+		`test:a:b:c:k=v:d:e`: "a,b,c|d,e,k",
+	} {
+		args, opt, ok := parseAnnotationArity(annotation, "test", 2, 4, nil)
+		if len(want) == 0 {
+			if ok {
+				t.Fatal(annotation, args)
+			}
+			continue
+		}
+		keys := make([]string, 0)
+		for k := range opt {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		ret := strings.Join(args, ",")
+		if len(keys) > 0 {
+			ret += "|" + strings.Join(keys, ",")
+		}
+		if !ok || ret != want {
+			t.Fatal(annotation, ret)
+		}
+	}
+
+	if args, _, _ := parseAnnotationArity(`test:a:b:c:d:e:f`, "test", 1, -1, nil); len(args) != 6 {
+		t.Fatal(args)
+	}
+}
+
+func TestConstMembers(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "a.go"), []byte(`package x
+
+// +zz:enum
+type Status int
+
+const (
+	_ Status = iota
+	// active status
+	// +zz:enum:label=Active
+	StatusActive
+	// +zz:enum:label=Inactive:json=inactive
+	// +zz:other:label=x
+	StatusInactive
+	StatusUnknown
+)
+
+const Other = 1
+`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "b.go"), []byte(`package x
+
+const StatusDeleted Status = -1
+
+const (
+	A int = iota
+	B
+)
+`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	decls, err := ParseFileDecls(filepath.Join(dir, "a.go"), AnnotationPrefix)
+	if err != nil || len(decls) == 0 || decls[0].TypeSpec == nil {
+		t.Fatal(decls, err)
+	}
+	entities := decls[0].parse("enum", 0, nil)
+	members := entities[0].ConstMembers()
+	var ret []string
+	for _, m := range members {
+		ret = append(ret, m.Name+"="+m.Options.Get("label", "")+m.Options.Get("json", ""))
+	}
+	if strings.Join(ret, ",") != "StatusActive=Active,StatusInactive=Inactiveinactive,StatusUnknown=,StatusDeleted=" {
+		t.Fatal(ret)
+	}
+	if strings.Join(members[0].Docs, ",") != "active status" || len(members[1].Annotations) != 1 {
+		t.Fatal(members)
+	}
+
+	// member annotations are separate entities by default
+	if decls, err = ParseFileOrDirectory(dir, AnnotationPrefix); err != nil {
+		t.Fatal(err)
+	}
+	if entities = decls.Parse(testEnum{}, nil); len(entities) != 3 || entities[0].Name() != "Status" ||
+		entities[1].Name() != "StatusActive" || entities[2].Name() != "StatusInactive" {
+		t.Fatal(entities)
+	}
+
+	// member annotations are omitted by config
+	config := ParseConfig{OmitConstMembers: true}
+	if entities = decls.ParseWithConfig(testEnum{}, nil, config); len(entities) != 1 || entities[0].Name() != "Status" {
+		t.Fatal(entities)
+	}
+	if entities = decls.ParseWithConfig(testEnum{name: "other"}, nil, config); len(entities) != 1 || entities[0].Name() != "StatusInactive" {
+		t.Fatal(entities)
+	}
+}
+
+type testEnum struct {
+	test
+	name string
+}
+
+func (p testEnum) Name() string {
+	if len(p.name) > 0 {
+		return p.name
+	}
+	return "enum"
+}
+
+func TestValidateAnnotationEscapes(t *testing.T) {
+	for annotation, want := range map[string]string{
+		`test:a\:b:path=C:\dir`: "",
+		`test:a\\n`:             "",
+		`test:a\n:b=\t\x`:       `\n \t`,
+	} {
+		err := ValidateAnnotationEscapes(annotation)
+		if len(want) == 0 && err != nil || len(want) > 0 && (err == nil || !strings.HasSuffix(err.Error(), want)) {
+			t.Fatal(annotation, err)
+		}
+	}
+
+	StrictResolution = true
+	defer func() { StrictResolution = false }()
+	if _, _, ok := parseAnnotation(`test:a\n`, "test", 1, nil); !ok {
+		t.Fatal(ok)
+	}
+	if err := TakeWarnings(); err == nil {
+		t.Fatal(err)
+	}
+}
+
+func TestOptionsMerge(t *testing.T) {
+	a, b := Options{"x": "a", "y": "a"}, Options{"y": "b", "z": "b"}
+	if ret := a.Merge(b, false); len(ret) != 3 || ret["x"] != "a" || ret["y"] != "a" || ret["z"] != "b" {
+		t.Fatal(ret)
+	}
+	if ret := a.Merge(b, true); len(ret) != 3 || ret["y"] != "b" || a["y"] != "a" {
+		t.Fatal(ret)
+	}
+	if ret := Options(nil).Merge(nil, true); ret == nil || len(ret) != 0 {
+		t.Fatal(ret)
+	}
+}
+
+func TestDeclEntityInputHash(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "a.go")
+	parse := func(data string) DeclEntity {
+		if err := os.WriteFile(filename, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		decls, err := ParseFileDecls(filename, AnnotationPrefix)
+		if err != nil || len(decls) != 1 {
+			t.Fatal(decls, err)
+		}
+		return decls.Parse(test{}, nil)[0]
+	}
+
+	const data = "package x\n\nimport \"time\"\n\n// +zz:test:a=1\ntype T struct{ Time time.Time }\n"
+	entity := parse(data)
+	entity.SaveInputHash("a_gen.go")
+	defer entityHashCache.Delete("a_gen.go")
+
+	if entity = parse(data + "\nfunc Unrelated() {}\n"); !entity.InputUnchanged("a_gen.go") {
+		t.Fatal("unrelated change")
+	}
+	if entity.InputUnchanged("b_gen.go") {
+		t.Fatal("not stored")
+	}
+	if entity = parse(strings.Replace(data, "a=1", "a=2", 1)); entity.InputUnchanged("a_gen.go") {
+		t.Fatal("options changed")
+	}
+	if entity = parse(strings.Replace(data, "Time time.Time", "Time time.Duration", 1)); entity.InputUnchanged("a_gen.go") {
+		t.Fatal("decl changed")
+	}
+}
+
+func TestDeclEntityInputHashReferences(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, data string) {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("go.mod", "module example.com/hash\n\ngo 1.16\n")
+	write("b.go", "package x\n\ntype U struct{ V *V }\n\ntype V struct{ X int }\n")
+	const data = "package x\n\n// T doc\n// +zz:test\ntype T struct {\n\tU\n\tM map[string][]U\n}\n"
+
+	hash := func(data string) string {
+		write("a.go", data)
+		decls, err := ParseFileDecls(filepath.Join(dir, "a.go"), AnnotationPrefix)
+		if err != nil || len(decls) != 1 {
+			t.Fatal(decls, err)
+		}
+		entity := decls.Parse(test{}, nil)[0]
+		return entity.InputHash()
+	}
+
+	origin := hash(data)
+	if h := hash(strings.Replace(data, "T doc", "T docs", 1)); h == origin {
+		t.Fatal("docs changed")
+	}
+	if h := hash(data); h != origin {
+		t.Fatal("unstable")
+	}
+	// indirect referenced type changed in another file
+	write("b.go", "package x\n\ntype U struct{ V *V }\n\ntype V struct{ X int64 }\n")
+	if h := hash(data); h == origin {
+		t.Fatal("referenced type changed")
+	}
+}
+
+func TestParseAnnotationJSON(t *testing.T) {
+	args, opt, ok := parseAnnotation(`test:arg:config={"a":1,"b":[2,3],"c":{"d":"x:}y"}}:key=value:u={"e":"é"}`, "test", 1, nil)
+	if !ok || len(args) != 1 || args[0] != "arg" || opt["key"] != "value" {
+		t.Fatal(args, opt, ok)
+	}
+	var config struct {
+		A int
+		B []int
+		C map[string]string
+	}
+	if err := Options(opt).JSON("config", &config); err != nil || config.A != 1 || len(config.B) != 2 || config.C["d"] != "x:}y" {
+		t.Fatal(config, err)
+	}
+	var u map[string]string
+	if err := Options(opt).JSON("u", &u); err != nil || u["e"] != "é" {
+		t.Fatal(u, err)
+	}
+	if err := Options(opt).JSON("none", &u); err != nil {
+		t.Fatal(err)
+	}
+	if err := Options(opt).JSON("key", &u); err == nil {
+		t.Fatal("invalid json")
+	}
+	if _, opt, _ = parseAnnotation(`test:a={:b=}`, "test", 0, nil); opt["a"] != "{:b=}" {
+		t.Fatal(opt)
+	}
+	if _, opt, _ = parseAnnotation(`test:a={:b=1`, "test", 0, nil); opt["a"] != "{" || opt["b"] != "1" {
+		t.Fatal(opt)
+	}
+}
@@ -20,15 +20,20 @@ package zcore
 import (
 	"bytes"
 	"crypto/md5"
+	"errors"
 	"fmt"
 	"go/ast"
 	"go/parser"
 	"go/token"
+	"io"
 	"io/fs"
 	"io/ioutil"
 	"os"
+	"path"
 	"path/filepath"
+	"sort"
 	"strings"
+	"time"
 )
 
 var (
@@ -36,6 +41,18 @@ var (
 	fileStore = new(VersionStore)
 	// ast store cached parsed file *ast.File with version key consists of size and modify time
 	astStore = new(VersionStore)
+	// file object store cached *File with absolute filename and version key
+	fileObjectStore = new(VersionStore)
+
+	// WriteDirPerm is permission of missing parent directories created by WriteFile
+	WriteDirPerm fs.FileMode = 0o775
+
+	// LineEnding is line ending of data written by WriteFile. default is "\n" as gofmt output.
+	// set as "\r\n" to convert all line endings of written data into CRLF
+	LineEnding = "\n"
+
+	// ErrEmptyFilename is returned by WriteFile with empty filename such as rejected by AnnotatedDecl.RelFilename
+	ErrEmptyFilename = errors.New("empty filename")
 )
 
 // fileVersion return file version key consists of size and modify time
@@ -43,7 +60,11 @@ func fileVersion(info os.FileInfo) string {
 	return fmt.Sprintf("%d-%s", info.Size(), info.ModTime())
 }
 
+// utf8BOM is byte order mark may be saved by editors at the beginning of utf-8 file
+var utf8BOM = []byte("\xef\xbb\xbf")
+
 // ReadFile try read filename and return data bytes.
+// leading utf-8 byte order mark would be stripped before parsing and annotations scanning.
 // data bytes would be cached by version key
 func ReadFile(filename string) (data []byte, version string, err error) {
 	info, err := os.Stat(filename)
@@ -52,7 +73,8 @@ func ReadFile(filename string) (data []byte, version string, err error) {
 	}
 	version = fileVersion(info)
 	r, err := fileStore.Load(filename, version, func() (interface{}, error) {
-		return ioutil.ReadFile(filename)
+		data, err := ioutil.ReadFile(filename)
+		return bytes.TrimPrefix(data, utf8BOM), err
 	})
 	if err != nil {
 		return
@@ -77,13 +99,45 @@ func ParseFile(filename string) (file *File, err error) {
 	return &File{Path: filename, Data: data, Ast: r.(*ast.File)}, nil
 }
 
+// GetFile returns cached *File of filename shared with ParseFileDecls.
+// filename would be converted as absolute path to be cache key.
+// cache is invalidated when file size or modify time changed, and files written by WriteFile
+// would be read and parsed again. same *File is returned while file is unchanged
+// so lazily loaded data like Imports would be shared
+func GetFile(filename string) (file *File, err error) {
+	if filename, err = filepath.Abs(filename); err != nil {
+		return
+	}
+	_, version, err := ReadFile(filename)
+	if err != nil {
+		return
+	}
+	r, err := fileObjectStore.Load(filename, version, func() (interface{}, error) {
+		return ParseFile(filename)
+	})
+	if err != nil {
+		return
+	}
+	return r.(*File), nil
+}
+
 // WriteFile checks data and exists filename md5 sum
-// and update data if file not exists or md5 sum not matched
+// and update data if file not exists or md5 sum not matched.
+// missing parent directories would be created with WriteDirPerm.
+// perm is used for new file and exists file would keep its own permission.
+// line endings of data would be converted as LineEnding.
+// empty filename would be rejected as ErrEmptyFilename
 func WriteFile(filename string, data []byte, perm fs.FileMode) (updated bool, err error) {
-	if err = os.MkdirAll(filepath.Dir(filename), 0o775); err != nil {
+	if len(filename) == 0 {
+		return false, ErrEmptyFilename
+	}
+
+	if err = os.MkdirAll(filepath.Dir(filename), WriteDirPerm); err != nil {
 		return
 	}
 
+	data = ConvertLineEnding(data, LineEnding)
+
 	// check file exist
 	exist, _, err := ReadFile(filename)
 	if err != nil {
@@ -113,6 +167,167 @@ func WriteFile(filename string, data []byte, perm fs.FileMode) (updated bool, er
 	return true, nil
 }
 
+// WriteFS represents fs.FS which supports writing file such as virtual filesystem
+type WriteFS interface {
+	fs.FS
+	WriteFile(name string, data []byte, perm fs.FileMode) error
+}
+
+// MemoryFS implements WriteFS in memory with slash separated file name as key.
+// directories are implied by file names.
+// it is not safe for concurrent use if any goroutine writes, so callers such as plugins running concurrently
+// should serialize access with their own lock
+type MemoryFS map[string]*MemoryFile
+
+// MemoryFile represents file data and permission of MemoryFS
+type MemoryFile struct {
+	Data []byte
+	Mode fs.FileMode
+}
+
+// Open implements fs.FS
+func (m MemoryFS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	if file := m[name]; file != nil {
+		return &memoryFile{Reader: bytes.NewReader(file.Data), info: memoryFileInfo{name: path.Base(name), file: file}}, nil
+	}
+
+	// directory implied by children file names
+	prefix := name + "/"
+	if name == "." {
+		prefix = ""
+	}
+	children := make(map[string]*MemoryFile)
+	for filename, file := range m {
+		if !strings.HasPrefix(filename, prefix) || file == nil {
+			continue
+		}
+		if rest := filename[len(prefix):]; strings.Contains(rest, "/") {
+			children[rest[:strings.Index(rest, "/")]] = nil
+		} else {
+			children[rest] = file
+		}
+	}
+	if len(children) == 0 && name != "." {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+
+	entries := make([]fs.DirEntry, 0, len(children))
+	for child, file := range children {
+		entries = append(entries, memoryFileInfo{name: child, file: file})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	return &memoryDir{info: memoryFileInfo{name: path.Base(name)}, entries: entries}, nil
+}
+
+// WriteFile implements WriteFS
+func (m MemoryFS) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	if !fs.ValidPath(name) {
+		return &fs.PathError{Op: "write", Path: name, Err: fs.ErrInvalid}
+	}
+	m[name] = &MemoryFile{Data: append([]byte(nil), data...), Mode: perm}
+	return nil
+}
+
+// memoryFileInfo implements fs.FileInfo and fs.DirEntry of MemoryFS. file is nil for directory
+type memoryFileInfo struct {
+	name string
+	file *MemoryFile
+}
+
+func (i memoryFileInfo) Name() string               { return i.name }
+func (i memoryFileInfo) IsDir() bool                { return i.file == nil }
+func (i memoryFileInfo) Type() fs.FileMode          { return i.Mode().Type() }
+func (i memoryFileInfo) Info() (fs.FileInfo, error) { return i, nil }
+func (i memoryFileInfo) ModTime() time.Time         { return time.Time{} }
+func (i memoryFileInfo) Sys() interface{}           { return nil }
+
+func (i memoryFileInfo) Size() int64 {
+	if i.file == nil {
+		return 0
+	}
+	return int64(len(i.file.Data))
+}
+
+func (i memoryFileInfo) Mode() fs.FileMode {
+	if i.file == nil {
+		return fs.ModeDir | 0o555
+	}
+	return i.file.Mode
+}
+
+// memoryFile implements fs.File of opened MemoryFS file
+type memoryFile struct {
+	*bytes.Reader
+	info memoryFileInfo
+}
+
+func (f *memoryFile) Stat() (fs.FileInfo, error) { return f.info, nil }
+func (f *memoryFile) Close() error               { return nil }
+
+// memoryDir implements fs.ReadDirFile of opened MemoryFS directory
+type memoryDir struct {
+	info    memoryFileInfo
+	entries []fs.DirEntry
+	offset  int
+}
+
+func (d *memoryDir) Stat() (fs.FileInfo, error) { return d.info, nil }
+func (d *memoryDir) Close() error               { return nil }
+
+func (d *memoryDir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.info.name, Err: fs.ErrInvalid}
+}
+
+func (d *memoryDir) ReadDir(n int) (entries []fs.DirEntry, err error) {
+	rest := d.entries[d.offset:]
+	if n > 0 && len(rest) == 0 {
+		return nil, io.EOF
+	}
+	if n > 0 && n < len(rest) {
+		rest = rest[:n]
+	}
+	d.offset += len(rest)
+	return rest, nil
+}
+
+// ReadFileFS reads name from fsys as ReadFile without caching.
+// leading utf-8 byte order mark would be stripped
+func ReadFileFS(fsys fs.FS, name string) (data []byte, err error) {
+	if data, err = fs.ReadFile(fsys, name); err != nil {
+		return
+	}
+	return bytes.TrimPrefix(data, utf8BOM), nil
+}
+
+// WriteFileFS writes data into name of fsys as WriteFile.
+// data would be written only if file not exists or md5 sum not matched
+// and exists file would keep its own permission
+func WriteFileFS(fsys WriteFS, name string, data []byte, perm fs.FileMode) (updated bool, err error) {
+	data = ConvertLineEnding(data, LineEnding)
+
+	exist, err := ReadFileFS(fsys, name)
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			return
+		}
+		return true, fsys.WriteFile(name, data, perm)
+	}
+
+	// compare exist and new data by md5 sum
+	oldSum := md5.Sum(exist)
+	if newSum := md5.Sum(data); bytes.Equal(newSum[:], oldSum[:]) {
+		return false, nil
+	}
+
+	if info, e := fs.Stat(fsys, name); e == nil {
+		perm = info.Mode()
+	}
+	return true, fsys.WriteFile(name, data, perm)
+}
+
 // WalkPackage walk package directory and parse file as *File. return *File map with filename
 func WalkPackage(dir string, fn func(file *File) (err error)) (files map[string]*File, err error) {
 	files = make(map[string]*File)
@@ -145,7 +360,32 @@ func WalkDir(dir string, fn func(filename string) error) (err error) {
 	})
 }
 
+// SafeJoin joins rel into base and rejects result escaping base via "..".
+// absolute rel is accepted only if it is located in base
+func SafeJoin(base, rel string) (string, error) {
+	target := filepath.Join(base, rel)
+	if filepath.IsAbs(rel) {
+		target = filepath.Clean(rel)
+	}
+	if r, err := filepath.Rel(base, target); err != nil || r == ".." || strings.HasPrefix(r, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("path %q escapes from %q", rel, base)
+	}
+	return target, nil
+}
+
 // IsGoFile check path is valid golang file and ignore test file
 func IsGoFile(path string) bool {
 	return strings.HasSuffix(path, ".go") && !strings.HasSuffix(path, "_test.go")
 }
+
+// ConvertLineEnding normalizes CRLF line endings of data into LF and then converts LF into provided line ending
+func ConvertLineEnding(data []byte, lineEnding string) []byte {
+	if lineEnding == "\n" && !bytes.Contains(data, []byte("\r\n")) {
+		return data
+	}
+	data = bytes.ReplaceAll(data, []byte("\r\n"), []byte("\n"))
+	if lineEnding == "\n" || len(lineEnding) == 0 {
+		return data
+	}
+	return bytes.ReplaceAll(data, []byte("\n"), []byte(lineEnding))
+}
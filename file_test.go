@@ -0,0 +1,171 @@
+/*
+ * Copyright (c) 2023 Maple Wu <justmaplewu@gmail.com>
+ *   National Electronics and Computer Technology Center, Thailand
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package zcore
+
+import (
+	"bytes"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestWriteFile(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "mocks", "nested", "x.go")
+	if updated, err := WriteFile(filename, []byte("package nested\n"), 0o600); err != nil || !updated {
+		t.Fatal(updated, err)
+	}
+	info, err := os.Stat(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0o600 {
+		t.Fatal(info.Mode())
+	}
+	if updated, err := WriteFile(filename, []byte("package nested\n"), 0o664); err != nil || updated {
+		t.Fatal(updated, err)
+	}
+	if data, _, err := ReadFile(filename); err != nil || !bytes.Equal(data, []byte("package nested\n")) {
+		t.Fatal(string(data), err)
+	}
+}
+
+func TestWriteFileLineEnding(t *testing.T) {
+	LineEnding = "\r\n"
+	defer func() { LineEnding = "\n" }()
+
+	filename := filepath.Join(t.TempDir(), "x.go")
+	if _, err := WriteFile(filename, []byte("package x\n\nvar _ = 1\r\n"), 0o664); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(filename); string(data) != "package x\r\n\r\nvar _ = 1\r\n" {
+		t.Fatalf("%q", data)
+	}
+	if updated, err := WriteFile(filename, []byte("package x\n\nvar _ = 1\n"), 0o664); err != nil || updated {
+		t.Fatal(updated, err)
+	}
+
+	LineEnding = "\n"
+	if data := ConvertLineEnding([]byte("a\r\nb\n"), LineEnding); string(data) != "a\nb\n" {
+		t.Fatalf("%q", data)
+	}
+}
+
+func TestGetFile(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "x.go")
+	if _, err := WriteFile(filename, []byte("package x\n\n// +zz:test\ntype T int\n"), 0o664); err != nil {
+		t.Fatal(err)
+	}
+	a, err := GetFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decls, err := ParseFileDecls(filename, AnnotationPrefix)
+	if err != nil || len(decls) != 1 || decls[0].File != a {
+		t.Fatal(decls, err)
+	}
+	if b, _ := GetFile(filename); b != a {
+		t.Fatal(b)
+	}
+	if _, err = WriteFile(filename, []byte("package y\n"), 0o664); err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := GetFile(filename); b == a || b.Ast.Name.Name != "y" {
+		t.Fatal(b)
+	}
+}
+
+func TestReadFileBOM(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "x.go")
+	if err := os.WriteFile(filename, []byte("\xef\xbb\xbf// +zz:test\n\npackage x\n\n// +zz:test:a\ntype T int\n"), 0o664); err != nil {
+		t.Fatal(err)
+	}
+	if data, _, err := ReadFile(filename); err != nil || !bytes.HasPrefix(data, []byte("// +zz:test\n")) {
+		t.Fatalf("%q %v", data, err)
+	}
+	if files, err := ListCandidateFiles(filename, AnnotationPrefix); err != nil || len(files) != 1 {
+		t.Fatal(files, err)
+	}
+	decls, err := ParseFileDecls(filename, AnnotationPrefix)
+	if err != nil || len(decls) != 1 || decls[0].Annotations[0] != "test:a" || string(decls[0].File.Node(decls[0].TypeSpec)) != "T int" {
+		t.Fatal(decls, err)
+	}
+	refs, err := CollectAnnotations(filename)
+	if err != nil || len(refs) != 2 || refs[0].Line != 1 || refs[0].Column != 4 {
+		t.Fatal(refs, err)
+	}
+}
+
+func TestWriteFileFS(t *testing.T) {
+	fsys := MemoryFS{}
+	if updated, err := WriteFileFS(fsys, "x/x.go", []byte("package x\n"), 0o600); err != nil || !updated {
+		t.Fatal(updated, err)
+	}
+	if updated, err := WriteFileFS(fsys, "x/x.go", []byte("package x\r\n"), 0o664); err != nil || updated {
+		t.Fatal(updated, err)
+	}
+	if updated, err := WriteFileFS(fsys, "x/x.go", []byte("package y\n"), 0o664); err != nil || !updated || fsys["x/x.go"].Mode != 0o600 {
+		t.Fatal(updated, err)
+	}
+	if data, err := ReadFileFS(fsys, "x/x.go"); err != nil || string(data) != "package y\n" {
+		t.Fatal(string(data), err)
+	}
+	if _, err := WriteFileFS(fsys, "../x.go", nil, 0o664); err == nil {
+		t.Fatal("invalid path")
+	}
+}
+
+func TestMemoryFS(t *testing.T) {
+	fsys := MemoryFS{
+		"a.go":     {Data: []byte("package a\n"), Mode: 0o644},
+		"x/b.go":   {Data: []byte("package x\n"), Mode: 0o600},
+		"x/y/c.go": {Data: []byte("package y\n"), Mode: 0o644},
+	}
+	if err := fstest.TestFS(fsys, "a.go", "x/b.go", "x/y/c.go"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fsys.Open("z"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatal(err)
+	}
+	if err := fsys.WriteFile("x/d.go", []byte("package x\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if entries, err := fs.ReadDir(fsys, "x"); err != nil || len(entries) != 3 || entries[1].Name() != "d.go" || !entries[2].IsDir() {
+		t.Fatal(entries, err)
+	}
+}
+
+func TestSafeJoin(t *testing.T) {
+	base := filepath.Join(string(filepath.Separator), "base")
+	for rel, ok := range map[string]bool{
+		"a/b.go":                       true,
+		"a/../b.go":                    true,
+		"../b.go":                      false,
+		"a/../../b.go":                 false,
+		"..b.go":                       true,
+		filepath.Join(base, "x"):       true,
+		filepath.Join(base, "..", "x"): false,
+	} {
+		if ret, err := SafeJoin(base, rel); (err == nil) != ok || ok && !strings.HasPrefix(ret, base) {
+			t.Fatal(rel, ret, err)
+		}
+	}
+}
@@ -0,0 +1,144 @@
+/*
+ * Copyright (c) 2023 Maple Wu <justmaplewu@gmail.com>
+ *   National Electronics and Computer Technology Center, Thailand
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package zcore
+
+import (
+	"bytes"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"go/types"
+)
+
+// FormatSource formats golang source as format.Source.
+// if simplify is true, source would be simplified as "gofmt -s" before formatting
+func FormatSource(src []byte, simplify bool) (data []byte, err error) {
+	if !simplify {
+		return format.Source(src)
+	}
+
+	fileSet := token.NewFileSet()
+	fileAst, err := parser.ParseFile(fileSet, "", src, parser.ParseComments)
+	if err != nil {
+		return
+	}
+
+	// sort imports as format.Source
+	ast.SortImports(fileSet, fileAst)
+	ast.Walk(simplifier{}, fileAst)
+
+	bf := BuffPool.Get().(*bytes.Buffer)
+	bf.Reset()
+	defer BuffPool.Put(bf)
+
+	if err = format.Node(bf, fileSet, fileAst); err != nil {
+		return
+	}
+	return append([]byte(nil), bf.Bytes()...), nil
+}
+
+// fragmentPackage is package clause added to format declarations fragment as complete file
+const fragmentPackage = "package p\n"
+
+// gofmtFragment formats top-level declarations fragment without package clause.
+// fragment is formatted as file with temporary package clause which would be removed from result
+func gofmtFragment(src []byte, simplify bool) (data []byte, err error) {
+	if data, err = FormatSource(append([]byte(fragmentPackage+"\n"), src...), simplify); err != nil {
+		return
+	}
+	return bytes.TrimLeft(bytes.TrimPrefix(data, []byte(fragmentPackage)), "\n"), nil
+}
+
+// simplifier implements ast.Visitor to simplify ast nodes as "gofmt -s":
+// composite literal type elision, slice expression "s[a:len(s)]" into "s[a:]"
+// and range clause "for x, _ = range" into "for x = range"
+type simplifier struct{}
+
+func (s simplifier) Visit(node ast.Node) ast.Visitor {
+	switch n := node.(type) {
+	case *ast.CompositeLit:
+		var elem, key ast.Expr
+		switch typ := n.Type.(type) {
+		case *ast.ArrayType:
+			elem = typ.Elt
+		case *ast.MapType:
+			key, elem = typ.Key, typ.Value
+		}
+		if elem != nil {
+			for i, x := range n.Elts {
+				if kv, ok := x.(*ast.KeyValueExpr); ok {
+					kv.Key = simplifyCompositeElem(kv.Key, key)
+					kv.Value = simplifyCompositeElem(kv.Value, elem)
+				} else {
+					n.Elts[i] = simplifyCompositeElem(x, elem)
+				}
+			}
+		}
+	case *ast.SliceExpr:
+		// s[a:len(s)] -> s[a:]
+		if n.Max != nil || n.High == nil {
+			break
+		}
+		if call, ok := n.High.(*ast.CallExpr); ok && len(call.Args) == 1 && !call.Ellipsis.IsValid() {
+			fn, ok1 := call.Fun.(*ast.Ident)
+			s, ok2 := n.X.(*ast.Ident)
+			arg, ok3 := call.Args[0].(*ast.Ident)
+			if ok1 && ok2 && ok3 && fn.Name == "len" && fn.Obj == nil && s.Name == arg.Name && s.Obj == arg.Obj {
+				n.High = nil
+			}
+		}
+	case *ast.RangeStmt:
+		// for x, _ = range -> for x = range
+		// for _ = range -> for range
+		if isBlankIdent(n.Value) {
+			n.Value = nil
+		}
+		if n.Value == nil && isBlankIdent(n.Key) {
+			n.Key = nil
+		}
+	}
+	return s
+}
+
+// simplifyCompositeElem elides type of composite literal element which equals to literal element type
+func simplifyCompositeElem(x ast.Expr, typ ast.Expr) ast.Expr {
+	if typ == nil {
+		return x
+	}
+	switch v := x.(type) {
+	case *ast.CompositeLit:
+		if v.Type != nil && types.ExprString(v.Type) == types.ExprString(typ) {
+			v.Type = nil
+		}
+	case *ast.UnaryExpr:
+		// &T{} with element type *T
+		ptr, ok := typ.(*ast.StarExpr)
+		if lit, isLit := v.X.(*ast.CompositeLit); ok && isLit && v.Op == token.AND &&
+			lit.Type != nil && types.ExprString(lit.Type) == types.ExprString(ptr.X) {
+			lit.Type = nil
+			return lit
+		}
+	}
+	return x
+}
+
+func isBlankIdent(x ast.Expr) bool {
+	ident, ok := x.(*ast.Ident)
+	return ok && ident.Name == "_"
+}
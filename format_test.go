@@ -0,0 +1,84 @@
+/*
+ * Copyright (c) 2023 Maple Wu <justmaplewu@gmail.com>
+ *   National Electronics and Computer Technology Center, Thailand
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package zcore
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFormatSource(t *testing.T) {
+	src := `package x
+
+import (
+	"strings"
+	"bytes"
+)
+
+type T struct{ A int }
+
+var (
+	a = []T{T{A: 1}, T{A: 2}}
+	b = []*T{&T{A: 1}}
+	c = map[T]T{T{A: 1}: T{A: 2}}
+	d = a[1:len(a)]
+)
+
+func f() {
+	for _ = range a {
+	}
+	for i, _ := range a {
+		_ = i
+	}
+}
+`
+	want := `package x
+
+import (
+	"bytes"
+	"strings"
+)
+
+type T struct{ A int }
+
+var (
+	a = []T{{A: 1}, {A: 2}}
+	b = []*T{{A: 1}}
+	c = map[T]T{{A: 1}: {A: 2}}
+	d = a[1:]
+)
+
+func f() {
+	for range a {
+	}
+	for i := range a {
+		_ = i
+	}
+}
+`
+	data, err := FormatSource([]byte(src), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != want {
+		t.Fatal(string(data))
+	}
+	if data, err = FormatSource([]byte(src), false); err != nil || !strings.Contains(string(data), "\"bytes\"\n\t\"strings\"") {
+		t.Fatal(string(data), err)
+	}
+}
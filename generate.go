@@ -19,13 +19,30 @@ package zcore
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"go/ast"
+	"go/build"
+	"go/build/constraint"
 	"go/format"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
 	"io"
 	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"runtime"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"text/template"
+	"unicode/utf8"
 )
 
 var (
@@ -38,52 +55,340 @@ var (
 		"camel":   LowerCamelCase,
 		"kebab":   KebabCase,
 		"comment": CommentLines,
+
+		"commentwrap": CommentLinesWrap,
 	}
 
 	templateStore = new(VersionStore)
+
+	// VerifyCompiles enables type checking output package with rendered data in RenderWrite
+	// and output file would not be written if type checking failed.
+	// it is disabled by default for speed and could be enabled in CI to catch broken generation
+	VerifyCompiles = false
 )
 
 const (
 	generateFormat = "// Code generated by %s:%s %s%s.\n\n"
-	cliRepo        = "github.com/go-zing/gozz"
+	// generatedSourceFormat records source declaration reference in generated header
+	generatedSourceFormat = "// Source: %s\n"
+	cliRepo               = "github.com/go-zing/gozz"
 )
 
 func CommentLines(comment string) string {
 	return "// " + strings.Replace(comment, "\n", "\n// ", -1)
 }
 
+// CommentLinesWrap formats comment as CommentLines and word-wraps lines longer than width
+// including "// " prefix. explicit newlines are preserved and single word longer than width would not be split.
+// non-positive width disables wrapping. in template: {{ commentwrap .Comment 80 }}
+func CommentLinesWrap(comment string, width int) string {
+	const prefix = "// "
+	if width <= 0 {
+		return CommentLines(comment)
+	}
+
+	lines := strings.Split(comment, "\n")
+	wrapped := make([]string, 0, len(lines))
+	for _, line := range lines {
+		if utf8.RuneCountInString(prefix+line) <= width {
+			wrapped = append(wrapped, line)
+			continue
+		}
+		current := ""
+		for _, word := range strings.Fields(line) {
+			if len(current) > 0 && utf8.RuneCountInString(prefix+current+" "+word) > width {
+				wrapped = append(wrapped, current)
+				current = ""
+			}
+			if len(current) > 0 {
+				current += " "
+			}
+			current += word
+		}
+		wrapped = append(wrapped, current)
+	}
+	return CommentLines(strings.Join(wrapped, "\n"))
+}
+
+// RenderConfig provides extra controls of golang file rendering
+type RenderConfig struct {
+	// Editable removes "DO NOT EDIT" tips from generated header
+	Editable bool
+
+	// Comments would be added as extra comments before package clause
+	Comments []string
+
+	// BuildTags represents build constraint expressions such as "linux" or "!windows && cgo".
+	// expressions would be combined with "&&" and emitted as "//go:build" and "// +build" lines
+	// on the very top of generated file
+	BuildTags []string
+
+	// GoVersion represents minimum go version of generated code such as "1.18" or "go1.18".
+	// it would be added as "go1.N" build constraint to guard newer syntax like generics on older toolchains
+	GoVersion string
+
+	// ExternalTest adds "_test" suffix to package clause for external test package file
+	ExternalTest bool
+
+	// Simplify simplifies generated source as "gofmt -s"
+	Simplify bool
+
+	// OmitHeader omits build constraints, generated code comment and extra comments
+	OmitHeader bool
+
+	// OmitPackage omits package clause so declarations fragment could be rendered and assembled later.
+	// fragment would be formatted as top-level declarations without package clause
+	OmitPackage bool
+
+	// Sources are source declarations references of generated file formatted by SourceRef like "user.go#User".
+	// they would be recorded in generated header for CleanGenerated to correlate generated file to its sources.
+	// if not provided, RenderWrite collects sources from entities found in template data. see TemplateSources
+	Sources []string
+}
+
 // RenderTemplate render golang file template and generate headers
 func RenderTemplate(plugin Plugin, templateText string, pkg string, editable bool, ext ...string) (data []byte, err error) {
+	return RenderConfig{Editable: editable, Comments: ext}.Render(plugin, templateText, pkg)
+}
+
+// RenderTemplateData render golang file template with provided template data instead of plugin and generate headers.
+// data could be any struct composed of entity and precomputed helpers such as resolved imports or sibling decls
+func RenderTemplateData(plugin Plugin, data interface{}, templateText string, pkg string, editable bool, ext ...string) ([]byte, error) {
+	return RenderConfig{Editable: editable, Comments: ext}.RenderData(plugin, data, templateText, pkg)
+}
+
+// Render render golang file template with config and generate headers
+func (config RenderConfig) Render(plugin Plugin, templateText string, pkg string) (data []byte, err error) {
+	return config.render(plugin, plugin, templateText, pkg)
+}
+
+// RenderData render golang file template with config and provided template data then generate headers
+func (config RenderConfig) RenderData(plugin Plugin, data interface{}, templateText string, pkg string) ([]byte, error) {
+	return config.render(plugin, data, templateText, pkg)
+}
+
+// RenderWrite render golang file template with config and write into filename
+func (config RenderConfig) RenderWrite(plugin Plugin, templateText, filename, pkg string) (err error) {
+	if len(config.Sources) == 0 {
+		config.Sources = TemplateSources(plugin, filename)
+	}
+	data, err := config.Render(plugin, templateText, pkg)
+	if err != nil {
+		return
+	}
+	if config.Editable {
+		if data, err = editableData(filename, data); err != nil || data == nil {
+			return
+		}
+	}
+	if VerifyCompiles {
+		if err = verifyPackage(filename, data); err != nil {
+			return
+		}
+	}
+	_, err = WriteFile(filename, data, 0o664)
+	return
+}
+
+// RenderMulti render multiple golang file templates with filename as key and generate headers.
+// each template would be executed with data of same filename key or plugin if not provided
+// and formatted independently. returns rendered data bytes with filename as key
+func RenderMulti(plugin Plugin, templates map[string]string, data map[string]interface{}, pkg string) (files map[string][]byte, err error) {
+	filenames := make([]string, 0, len(templates))
+	for filename := range templates {
+		filenames = append(filenames, filename)
+	}
+	sort.Strings(filenames)
+
+	files = make(map[string][]byte, len(templates))
+	for _, filename := range filenames {
+		var v interface{} = plugin
+		if d, ok := data[filename]; ok {
+			v = d
+		}
+		if files[filename], err = (RenderConfig{}).render(plugin, v, templates[filename], pkg); err != nil {
+			return nil, fmt.Errorf("render %s: %w", filename, err)
+		}
+	}
+	return
+}
+
+// render render golang file template with template data and generate headers
+func (config RenderConfig) render(plugin Plugin, v interface{}, templateText string, pkg string) (data []byte, err error) {
 	bf := BuffPool.Get().(*bytes.Buffer)
 	bf.Reset()
 
 	defer BuffPool.Put(bf)
 
+	if err = config.writeHeader(bf, plugin, pkg); err != nil {
+		return
+	}
+
+	// execute template
+	if err = ExecuteTemplate(v, templateText, bf); err != nil {
+		return
+	}
+
+	formatter := FormatSource
+	if config.OmitPackage {
+		formatter = gofmtFragment
+	}
+	if data, err = formatter(bf.Bytes(), config.Simplify); err != nil {
+		Logger.Printf("%s\n", bf.Bytes())
+		return
+	}
+	return
+}
+
+// writeHeader writes generated header and package clause into buffer unless omitted by config
+func (config RenderConfig) writeHeader(bf *bytes.Buffer, plugin Plugin, pkg string) (err error) {
+	if !config.OmitHeader {
+		if err = config.writeGeneratedHeader(bf, plugin); err != nil {
+			return
+		}
+	}
+
+	// package
+	if config.OmitPackage {
+		return
+	}
+	if config.ExternalTest && !strings.HasSuffix(pkg, "_test") {
+		pkg += "_test"
+	}
+	_, _ = fmt.Fprintf(bf, "package %s\n\n", pkg)
+	return
+}
+
+// writeGeneratedHeader writes build constraints, generated code comment and extra comments into buffer
+func (config RenderConfig) writeGeneratedHeader(bf *bytes.Buffer, plugin Plugin) (err error) {
+	// build constraints
+	tags := config.BuildTags
+	if len(config.GoVersion) > 0 {
+		version := "go" + strings.TrimPrefix(config.GoVersion, "go")
+		if !goVersionRegexp.MatchString(version) {
+			return fmt.Errorf("invalid go version %q", config.GoVersion)
+		}
+		tags = append(append([]string(nil), tags...), version)
+	}
+	if err = writeBuildConstraints(bf, tags); err != nil {
+		return
+	}
+
 	tips := ". DO NOT EDIT"
-	if editable {
+	if config.Editable {
 		tips = ""
 	}
 
 	// code generate comment
-	_, _ = fmt.Fprintf(bf, generateFormat, ExecName, plugin.Name(), cliRepo, tips)
+	header := fmt.Sprintf(generateFormat, ExecName, plugin.Name(), cliRepo, tips)
+	if len(config.Sources) > 0 {
+		header = strings.TrimSuffix(header, "\n")
+		for _, source := range config.Sources {
+			header += fmt.Sprintf(generatedSourceFormat, source)
+		}
+		header += "\n"
+	}
+	bf.WriteString(header)
 
 	// extra comments before package
-	for i, str := range ext {
+	for i, str := range config.Comments {
 		bf.WriteString(str)
 		bf.WriteRune('\n')
-		if len(ext)-1 == i {
+		if len(config.Comments)-1 == i {
 			bf.WriteRune('\n')
 		}
 	}
+	return
+}
+
+// goVersionRegexp matches release tags of go version build constraint
+var goVersionRegexp = regexp.MustCompile(`^go1\.\d+$`)
+
+// writeBuildConstraints validates build tags expressions and writes "//go:build" and "// +build" lines into buffer
+func writeBuildConstraints(bf *bytes.Buffer, tags []string) (err error) {
+	if len(tags) == 0 {
+		return
+	}
+
+	var expr constraint.Expr
+	for _, tag := range tags {
+		x, e := constraint.Parse("//go:build " + tag)
+		if e != nil {
+			return fmt.Errorf("invalid build tags %q: %w", tag, e)
+		}
+		if expr == nil {
+			expr = x
+		} else {
+			expr = &constraint.AndExpr{X: expr, Y: x}
+		}
+	}
 
-	// package
-	_, _ = fmt.Fprintf(bf, "package %s\n\n", pkg)
+	lines, err := constraint.PlusBuildLines(expr)
+	if err != nil {
+		return
+	}
 
-	// execute template
-	if err = ExecuteTemplate(plugin, templateText, bf); err != nil {
+	_, _ = fmt.Fprintf(bf, "//go:build %s\n", expr)
+	for _, line := range lines {
+		bf.WriteString(line)
+		bf.WriteRune('\n')
+	}
+	bf.WriteRune('\n')
+	return
+}
+
+// FileBuilder accumulates rendered declaration fragments and required imports.
+// fragments should use names returned from Imports.Add to reference imported packages
+// then fragments would be built into single golang file with deduplicated imports block
+type FileBuilder struct {
+	Package string
+	Imports Imports
+	// Sources are source declarations references recorded in generated header. see RenderConfig.Sources
+	Sources   []string
+	fragments [][]byte
+}
+
+// Add appends rendered declaration fragment into builder
+func (b *FileBuilder) Add(fragment []byte) {
+	b.fragments = append(b.fragments, append([]byte(nil), fragment...))
+}
+
+// Execute executes template with data and appends result as fragment
+func (b *FileBuilder) Execute(data interface{}, templateText string) (err error) {
+	bf := &bytes.Buffer{}
+	if err = ExecuteTemplate(data, templateText, bf); err != nil {
+		return
+	}
+	b.Add(bf.Bytes())
+	return
+}
+
+// Render generate headers and merged imports then joins all fragments as formatted golang file
+func (b *FileBuilder) Render(plugin Plugin, editable bool, ext ...string) (data []byte, err error) {
+	bf := BuffPool.Get().(*bytes.Buffer)
+	bf.Reset()
+
+	defer BuffPool.Put(bf)
+
+	if err = (RenderConfig{Editable: editable, Comments: ext, Sources: b.Sources}).writeHeader(bf, plugin, b.Package); err != nil {
 		return
 	}
 
+	// merged imports
+	if len(b.Imports) > 0 {
+		bf.WriteString("import (\n")
+		for _, imp := range b.Imports.List() {
+			_, _ = fmt.Fprintf(bf, "\t%s %s\n", imp.Name, strconv.Quote(imp.Path))
+		}
+		bf.WriteString(")\n\n")
+	}
+
+	// fragments
+	for _, fragment := range b.fragments {
+		bf.Write(fragment)
+		bf.WriteString("\n\n")
+	}
+
 	if data, err = format.Source(bf.Bytes()); err != nil {
 		Logger.Printf("%s\n", bf.Bytes())
 		return
@@ -91,6 +396,152 @@ func RenderTemplate(plugin Plugin, templateText string, pkg string, editable boo
 	return
 }
 
+// RenderWrite render builder fragments as golang file and write into filename
+func (b *FileBuilder) RenderWrite(plugin Plugin, filename string, editable bool, ext ...string) (err error) {
+	data, err := b.Render(plugin, editable, ext...)
+	if err != nil {
+		return
+	}
+	if editable {
+		if data, err = editableData(filename, data); err != nil || data == nil {
+			return
+		}
+	}
+	_, err = WriteFile(filename, data, 0o664)
+	return
+}
+
+// DirAggregator renders all entities in same directory into single golang file
+// such as dependency injection wiring or registry which references all annotated declarations of directory.
+// template is executed once with aggregator as data and could add imports by {{ .Imports.Add "path" }}
+type DirAggregator struct {
+	Dir      string
+	Package  string
+	Entities DeclEntities
+	Imports  Imports
+}
+
+// NewDirAggregators groups entities by declaration directory into aggregators sorted by directory.
+// entities of each aggregator are sorted by filename and declaration position for stable output
+func NewDirAggregators(entities DeclEntities) (aggregators []*DirAggregator) {
+	groups := entities.GroupByDir()
+	dirs := make([]string, 0, len(groups))
+	for dir := range groups {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+
+	for _, dir := range dirs {
+		group := append(DeclEntities(nil), groups[dir]...)
+		sort.SliceStable(group, func(i, j int) bool {
+			if a, b := group[i].File.Path, group[j].File.Path; a != b {
+				return a < b
+			}
+			return group[i].Pos() < group[j].Pos()
+		})
+		aggregators = append(aggregators, &DirAggregator{
+			Dir:      dir,
+			Package:  group[0].Package(),
+			Entities: group,
+			Imports:  make(Imports),
+		})
+	}
+	return
+}
+
+// Render executes template with aggregator and renders as golang file with deduplicated imports block.
+// entities would be recorded as sources relative to aggregator directory in generated header
+func (a *DirAggregator) Render(plugin Plugin, templateText string, editable bool, ext ...string) (data []byte, err error) {
+	return a.render(plugin, templateText, a.Dir, editable, ext...)
+}
+
+// render renders aggregator with entities sources relative to provided output directory
+func (a *DirAggregator) render(plugin Plugin, templateText, dir string, editable bool, ext ...string) (data []byte, err error) {
+	if a.Imports == nil {
+		a.Imports = make(Imports)
+	}
+	b := &FileBuilder{Package: a.Package, Imports: a.Imports, Sources: EntitySources(a.Entities, dir)}
+	if err = b.Execute(a, templateText); err != nil {
+		return
+	}
+	return b.Render(plugin, editable, ext...)
+}
+
+// RenderWrite renders aggregator as golang file and write into filename related to aggregator directory
+func (a *DirAggregator) RenderWrite(plugin Plugin, templateText, filename string, editable bool, ext ...string) (err error) {
+	if !filepath.IsAbs(filename) {
+		filename = filepath.Join(a.Dir, filename)
+	}
+	data, err := a.render(plugin, templateText, filepath.Dir(filename), editable, ext...)
+	if err != nil {
+		return
+	}
+	if editable {
+		if data, err = editableData(filename, data); err != nil || data == nil {
+			return
+		}
+	}
+	_, err = WriteFile(filename, data, 0o664)
+	return
+}
+
+// RenderConcurrency limits workers count of RenderEach. use cpu count if not positive
+var RenderConcurrency = runtime.NumCPU()
+
+// RenderEach runs fn for each entity across bounded workers and writes returned data into filename.
+// it suits generators which render one file per entity since formatting source is cpu bound.
+// results are written in entities order and empty filename would be skipped.
+// errors of all entities are aggregated in entities order
+func RenderEach(entities DeclEntities, fn func(entity DeclEntity) (filename string, data []byte, err error)) error {
+	workers := RenderConcurrency
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	if workers > len(entities) {
+		workers = len(entities)
+	}
+
+	type result struct {
+		filename string
+		data     []byte
+		err      error
+	}
+
+	results := make([]result, len(entities))
+	indexes := make(chan int)
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range indexes {
+				r := &results[index]
+				r.filename, r.data, r.err = fn(entities[index])
+			}
+		}()
+	}
+	for i := range entities {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+
+	var lines []string
+	for i, r := range results {
+		if r.err == nil && len(r.filename) > 0 {
+			_, r.err = WriteFile(r.filename, r.data, 0o664)
+		}
+		if r.err != nil {
+			Appendf(&lines, "%s:%s: %v", entities[i].File.Path, entities[i].Name(), r.err)
+		}
+	}
+	if len(lines) == 0 {
+		return nil
+	}
+	return fmt.Errorf("render failed:\n%s", strings.Join(lines, "\n"))
+}
+
 // getTemplate parse text as *template.Template
 // parsed templates would be cached in templateStore with template text as key
 func getTemplate(text string) (tmpl *template.Template, err error) {
@@ -104,7 +555,15 @@ func getTemplate(text string) (tmpl *template.Template, err error) {
 	return
 }
 
-// ExecuteTemplate parse provide text template and execute template data into writer
+var (
+	// ErrTemplateParse wraps error of template text parsing such as bad syntax
+	ErrTemplateParse = errors.New("template parse error")
+	// ErrTemplateExecute wraps error of template executing such as unknown field
+	ErrTemplateExecute = errors.New("template execute error")
+)
+
+// ExecuteTemplate parse provide text template and execute template data into writer.
+// returns error wrapped ErrTemplateParse or ErrTemplateExecute
 func ExecuteTemplate(data interface{}, text string, writer io.Writer) (err error) {
 	if !(strings.Contains(text, "{{") && strings.Contains(text, "}}")) {
 		_, err = writer.Write(UnsafeString2Bytes(text))
@@ -112,26 +571,401 @@ func ExecuteTemplate(data interface{}, text string, writer io.Writer) (err error
 	}
 	tmpl, err := getTemplate(text)
 	if err != nil {
-		return
+		return fmt.Errorf("%w: %v", ErrTemplateParse, err)
+	}
+	if err = tmpl.Execute(writer, data); err != nil {
+		return fmt.Errorf("%w: %v", ErrTemplateExecute, err)
 	}
-	return tmpl.Execute(writer, data)
+	return
 }
 
-// TryExecuteTemplate try execute template, if success replace value to string pointer
-func TryExecuteTemplate(data interface{}, text string, dst *string) {
+// TryExecuteTemplate try execute template, if success replace value to string pointer.
+// returns error wrapped ErrTemplateParse or ErrTemplateExecute and keep value unchanged on failure
+func TryExecuteTemplate(data interface{}, text string, dst *string) (err error) {
 	str := &strings.Builder{}
-	if ExecuteTemplate(data, text, str) == nil {
+	if err = ExecuteTemplate(data, text, str); err == nil {
 		*dst = str.String()
 	}
+	return
 }
 
 // RenderWrite render golang file template and write into filename
 func RenderWrite(plugin Plugin, templateText, filename, pkg string, editable bool, ext ...string) (err error) {
-	data, err := RenderTemplate(plugin, templateText, pkg, editable, ext...)
+	return RenderConfig{Editable: editable, Comments: ext}.RenderWrite(plugin, templateText, filename, pkg)
+}
+
+// RenderRaw executes template with data and write result into filename verbatim.
+// no golang header is generated and result would not be formatted as golang source
+// so it could be used to generate companion files like json or yaml
+func RenderRaw(data interface{}, templateText, filename string) (err error) {
+	bf := BuffPool.Get().(*bytes.Buffer)
+	bf.Reset()
+
+	defer BuffPool.Put(bf)
+
+	if err = ExecuteTemplate(data, templateText, bf); err != nil {
+		return
+	}
+	_, err = WriteFile(filename, bf.Bytes(), 0o664)
+	return
+}
+
+// VerifyPackage type checks package of directory which filename located in.
+// type errors in filename would be reported first or else first error of package would be returned
+func VerifyPackage(filename string) (err error) { return verifyPackage(filename, nil) }
+
+// verifyPackage type checks package as VerifyPackage with src as data of filename if provided
+// then filename could be checked before written
+func verifyPackage(filename string, src []byte) (err error) {
+	if filename, err = filepath.Abs(filename); err != nil {
+		return
+	}
+
+	dir := filepath.Dir(filename)
+	fileSet := token.NewFileSet()
+	files := make([]*ast.File, 0)
+
+	ctx := build.Default
+	if src != nil {
+		// build constraints of filename are matched with src
+		ctx.OpenFile = func(path string) (io.ReadCloser, error) {
+			if path == filename {
+				return io.NopCloser(bytes.NewReader(src)), nil
+			}
+			return os.Open(path)
+		}
+	}
+	parse := func(name string) (err error) {
+		if match, e := ctx.MatchFile(dir, filepath.Base(name)); e != nil || !match {
+			return e
+		}
+		data := src
+		if name != filename || src == nil {
+			if data, _, err = ReadFile(name); err != nil {
+				return
+			}
+		}
+		f, err := parser.ParseFile(fileSet, name, data, 0)
+		if err != nil {
+			return
+		}
+		files = append(files, f)
+		return
+	}
+
+	// parse package files matched build constraints with shared file set
+	exist := false
+	if err = WalkDir(dir, func(name string) (err error) {
+		if !IsGoFile(name) {
+			return
+		}
+		exist = exist || name == filename
+		return parse(name)
+	}); err == nil && src != nil && !exist {
+		err = parse(filename)
+	}
 	if err != nil {
+		return fmt.Errorf("verify %s: %w", filename, err)
+	}
+
+	var errs []error
+	config := types.Config{
+		Importer: importer.ForCompiler(fileSet, "source", nil),
+		Error:    func(err error) { errs = append(errs, err) },
+	}
+	_, _ = config.Check(GetImportPath(dir), fileSet, files, nil)
+
+	if len(errs) == 0 {
+		return nil
+	}
+
+	for _, e := range errs {
+		if te, ok := e.(types.Error); ok && te.Fset.Position(te.Pos).Filename == filename {
+			return fmt.Errorf("verify %s: %w", filename, e)
+		}
+	}
+	return fmt.Errorf("verify %s: %w", filename, errs[0])
+}
+
+// RenderScaffold render golang file template as editable file and write into filename only if file not exists.
+// scaffold is generated once and would never be overwritten by subsequent runs to keep user edits
+func RenderScaffold(plugin Plugin, templateText, filename, pkg string, ext ...string) (written bool, err error) {
+	if _, err = os.Stat(filename); err == nil || !os.IsNotExist(err) {
+		return false, err
+	}
+	if err = RenderWrite(plugin, templateText, filename, pkg, true, ext...); err != nil {
 		return
 	}
-	_, err = WriteFile(filename, data, 0o664)
+	return true, nil
+}
+
+var (
+	// ErrUserEdited represents editable generated file has been edited after generation.
+	// edited file is skipped with warning and it is returned only in StrictResolution mode
+	ErrUserEdited = errors.New("generated file has been edited")
+
+	// OverwriteEdited allows editable generated files edited by user to be overwritten
+	OverwriteEdited = false
+)
+
+// checksumPrefix marks checksum line of generated content in editable file header
+const checksumPrefix = "// " + ExecName + ":checksum "
+
+// editableData checks exists editable file of filename has not been edited
+// then returns data with checksum line added after generated header.
+// edited file would be skipped with nil data and warning, or returns ErrUserEdited in strict resolution mode.
+// exists file without checksum line is generated by earlier version and would be overwritten
+func editableData(filename string, data []byte) ([]byte, error) {
+	if exist, _, err := ReadFile(filename); err == nil && !OverwriteEdited {
+		if sum, content, ok := splitChecksum(exist); ok && sum != contentChecksum(content) {
+			err = fmt.Errorf("%w: %s", ErrUserEdited, filename)
+			if StrictResolution {
+				return nil, err
+			}
+			Warnf("%v. skipped", err)
+			return nil, nil
+		}
+	} else if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	line := checksumPrefix + contentChecksum(data) + "\n"
+	lines := strings.SplitAfter(UnsafeBytes2String(data), "\n")
+	for i, l := range lines {
+		if strings.HasPrefix(l, "// Code generated by ") {
+			return []byte(strings.Join(lines[:i+1], "") + line + strings.Join(lines[i+1:], "")), nil
+		}
+	}
+	return append([]byte(line), data...), nil
+}
+
+// splitChecksum returns checksum in data and content without checksum line
+func splitChecksum(data []byte) (sum string, content []byte, ok bool) {
+	lines := strings.SplitAfter(string(ConvertLineEnding(data, "\n")), "\n")
+	for i, line := range lines {
+		if v, exist := TrimPrefix(line, checksumPrefix); exist {
+			return strings.TrimSpace(v), []byte(strings.Join(lines[:i], "") + strings.Join(lines[i+1:], "")), true
+		}
+	}
+	return
+}
+
+func contentChecksum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// generatedHeaderRegexp matches generated header of gozz and captures plugin name
+var generatedHeaderRegexp = regexp.MustCompile(`^// Code generated by ` + ExecName + `:(\S+) .*DO NOT EDIT\.$`)
+
+// GeneratedPlugin checks leading comments of golang file data contains gozz generated header
+// and returns plugin name in header. editable generated file would not be matched
+func GeneratedPlugin(data []byte) (plugin string, ok bool) {
+	for _, line := range strings.Split(UnsafeBytes2String(data), "\n") {
+		line = strings.TrimSpace(line)
+		if sub := generatedHeaderRegexp.FindStringSubmatch(line); len(sub) > 1 {
+			return sub[1], true
+		}
+		// only leading comments before package clause
+		if len(line) > 0 && !strings.HasPrefix(line, "//") {
+			return
+		}
+	}
+	return
+}
+
+// IsGeneratedFile checks filename is generated by gozz and not editable
+func IsGeneratedFile(filename string) bool {
+	data, _, err := ReadFile(filename)
+	if err != nil {
+		return false
+	}
+	_, ok := GeneratedPlugin(data)
+	return ok
+}
+
+// generatedSourceRegexp matches source declaration reference line of generated header
+var generatedSourceRegexp = regexp.MustCompile(`^// Source: (\S+)$`)
+
+// GeneratedSources returns source declarations references recorded in leading comments of generated file data
+func GeneratedSources(data []byte) (sources []string) {
+	for _, line := range strings.Split(UnsafeBytes2String(data), "\n") {
+		line = strings.TrimSpace(line)
+		if sub := generatedSourceRegexp.FindStringSubmatch(line); len(sub) > 1 {
+			sources = append(sources, sub[1])
+		}
+		// only leading comments before package clause
+		if len(line) > 0 && !strings.HasPrefix(line, "//") {
+			return
+		}
+	}
+	return
+}
+
+// SourceRef formats source declaration reference as "$filename#$name" for RenderConfig.Sources.
+// filename is slash separated path relative to dir of generated file
+func SourceRef(decl *AnnotatedDecl, dir string) string {
+	filename := decl.File.Path
+	if rel, err := filepath.Rel(dir, filename); err == nil {
+		filename = rel
+	}
+	return filepath.ToSlash(filename) + "#" + decl.Name()
+}
+
+// EntitySources returns deduplicated source declarations references of entities in order. see SourceRef
+func EntitySources(entities DeclEntities, dir string) (sources []string) {
+	exist := make(map[string]bool, len(entities))
+	for _, entity := range entities {
+		if entity.AnnotatedDecl == nil || entity.File == nil {
+			continue
+		}
+		if ref := SourceRef(entity.AnnotatedDecl, dir); !exist[ref] {
+			exist[ref] = true
+			sources = append(sources, ref)
+		}
+	}
+	return
+}
+
+// templateSourcesDepth limits nested levels of template data walked by TemplateSources
+const templateSourcesDepth = 4
+
+var (
+	declEntityType    = reflect.TypeOf(DeclEntity{})
+	annotatedDeclType = reflect.TypeOf(AnnotatedDecl{})
+	fileType          = reflect.TypeOf(File{})
+)
+
+// TemplateSources returns sorted source declarations references of DeclEntity values found in template data
+// relative to directory of generated filename. exported struct fields, slices, arrays and map values
+// would be walked within limited nested levels. see SourceRef
+func TemplateSources(data interface{}, filename string) (sources []string) {
+	dir, err := filepath.Abs(filepath.Dir(filename))
+	if err != nil {
+		return
+	}
+
+	var entities DeclEntities
+	var walk func(v reflect.Value, depth int)
+	walk = func(v reflect.Value, depth int) {
+		for v.IsValid() && (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) && !v.IsNil() {
+			v = v.Elem()
+		}
+		if !v.IsValid() || depth > templateSourcesDepth {
+			return
+		}
+		switch v.Type() {
+		case declEntityType:
+			if v.CanInterface() {
+				entities = append(entities, v.Interface().(DeclEntity))
+			}
+			return
+		case annotatedDeclType, fileType:
+			return
+		}
+		switch v.Kind() {
+		case reflect.Struct:
+			for i := 0; i < v.NumField(); i++ {
+				if v.Type().Field(i).PkgPath == "" {
+					walk(v.Field(i), depth+1)
+				}
+			}
+		case reflect.Slice, reflect.Array:
+			for i := 0; i < v.Len(); i++ {
+				walk(v.Index(i), depth+1)
+			}
+		case reflect.Map:
+			for iter := v.MapRange(); iter.Next(); {
+				walk(iter.Value(), depth+1)
+			}
+		}
+	}
+	walk(reflect.ValueOf(data), 0)
+
+	sources = EntitySources(entities, dir)
+	sort.Strings(sources)
+	return
+}
+
+// CleanGenerated walks root and returns stale generated files sorted by filename.
+// generated file is correlated to sources recorded in its header. see RenderConfig.Sources.
+// it is stale only when none of its sources declaration still exists with annotation of plugin in header.
+// generated files without recorded sources are never considered stale.
+// stale files would be removed only if remove is true or else it would be a dry run
+func CleanGenerated(root string, remove bool) (stale []string, err error) {
+	if err = WalkTree(root, func(filename string) (err error) {
+		if !strings.HasSuffix(filename, ".go") {
+			return
+		}
+		data, _, err := ReadFile(filename)
+		if err != nil {
+			return
+		}
+		plugin, ok := GeneratedPlugin(data)
+		if !ok {
+			return
+		}
+		sources := GeneratedSources(data)
+		if len(sources) == 0 {
+			return
+		}
+		for _, source := range sources {
+			if exist, e := sourceExists(filepath.Dir(filename), source, plugin); e != nil || exist {
+				return e
+			}
+		}
+		stale = append(stale, filename)
+		return
+	}); err != nil {
+		return
+	}
+
+	sort.Strings(stale)
+
+	if remove {
+		for _, filename := range stale {
+			if err = os.Remove(filename); err != nil {
+				return
+			}
+		}
+	}
+	return
+}
+
+// sourceExists checks source reference resolved from dir is declaration annotated by plugin or its aliases
+func sourceExists(dir, source, plugin string) (exist bool, err error) {
+	filename, name := SplitKV(source, "#")
+	if !filepath.IsAbs(filename) {
+		filename = filepath.Join(dir, filepath.FromSlash(filename))
+	}
+	if _, err = os.Stat(filename); os.IsNotExist(err) {
+		return false, nil
+	} else if err != nil {
+		return
+	}
+
+	decls, err := ParseFileDecls(filename, AnnotationPrefix)
+	if err != nil {
+		return
+	}
+	names := map[string]bool{plugin: true}
+	for _, alias := range PluginAliases(plugin) {
+		names[alias] = true
+	}
+	for _, decl := range decls {
+		if decl.Name() != name {
+			continue
+		}
+		annotations := decl.Annotations
+		for _, field := range decl.Fields {
+			annotations = append(annotations[:len(annotations):len(annotations)], field.Annotations...)
+		}
+		for _, annotation := range annotations {
+			if names[AnnotationName(annotation)] {
+				return true, nil
+			}
+		}
+	}
 	return
 }
 
@@ -155,3 +989,47 @@ func GetOrWriteDefault(filename string, defaultData []byte) ([]byte, error) {
 	}
 	return defaultData, nil
 }
+
+// TemplateOptionKey is annotation option key to specify custom template file of declaration
+const TemplateOptionKey = "template"
+
+// TemplateRoot restricts template files of TemplateOptionKey option located in root directory.
+// if empty, module directory of working directory would be used or working directory if not in module.
+// relative paths are resolved from root and absolute paths should be located in root too
+var TemplateRoot = ""
+
+// templateRoot returns absolute directory restricts template files
+func templateRoot() (string, error) {
+	if len(TemplateRoot) > 0 {
+		return filepath.Abs(TemplateRoot)
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+	if modFile := GetModFile(wd); len(modFile) > 0 && modFile != os.DevNull {
+		return filepath.Dir(modFile), nil
+	}
+	return wd, nil
+}
+
+// ResolveTemplate returns template text from file of TemplateOptionKey option if provided or else default text.
+// template file would be read with file version cache
+func ResolveTemplate(options Options, defaultText string) (string, error) {
+	filename := options.Get(TemplateOptionKey, "")
+	if len(filename) == 0 {
+		return defaultText, nil
+	}
+	root, err := templateRoot()
+	if err != nil {
+		return "", err
+	}
+	if filename, err = SafeJoin(root, filename); err != nil {
+		return "", fmt.Errorf("resolve template: %w", err)
+	}
+	data, _, err := ReadFile(filename)
+	if err != nil {
+		return "", fmt.Errorf("read template %s: %w", filename, err)
+	}
+	return string(data), nil
+}
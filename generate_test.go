@@ -19,6 +19,13 @@ package zcore
 
 import (
 	"bytes"
+	"errors"
+	"go/parser"
+	"go/token"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -62,3 +69,530 @@ func TestRenderTemplate(t *testing.T) {
 		t.Fatalf("%s", b)
 	}
 }
+
+func TestCommentLinesWrap(t *testing.T) {
+	comment := "user account name of login which is unique\n\nshort line"
+	if ret := CommentLinesWrap(comment, 20); ret != "// user account name\n// of login which is\n// unique\n// \n// short line" {
+		t.Fatal(ret)
+	}
+	if ret := CommentLinesWrap("extremely_long_identifier word", 10); ret != "// extremely_long_identifier\n// word" {
+		t.Fatal(ret)
+	}
+	if ret := CommentLinesWrap(comment, 0); ret != CommentLines(comment) {
+		t.Fatal(ret)
+	}
+}
+
+func TestVerifyPackage(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "x.go")
+	if err := os.WriteFile(filename, []byte("package x\n\nvar _ = fmt.Sprint\n"), 0o664); err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifyPackage(filename); err == nil || !strings.Contains(err.Error(), filename) {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filename, []byte("package x\n\nimport \"fmt\"\n\nvar _ = fmt.Sprint\n"), 0o664); err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifyPackage(filename); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRenderWriteVerifyCompiles(t *testing.T) {
+	VerifyCompiles = true
+	defer func() { VerifyCompiles = false }()
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "a.go"), []byte("package x\n\nvar A = 1\n"), 0o664); err != nil {
+		t.Fatal(err)
+	}
+	filename := filepath.Join(dir, "x_gen.go")
+	if err := RenderWrite(test{}, "var _ = Undefined", filename, "x", false); err == nil || !strings.Contains(err.Error(), filename) {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filename); !os.IsNotExist(err) {
+		t.Fatal(err)
+	}
+	if err := RenderWrite(test{}, "var _ = A", filename, "x", false); err != nil {
+		t.Fatal(err)
+	}
+
+	// exist output is kept if type checking failed
+	if err := RenderWrite(test{}, "var _ = A + Undefined", filename, "x", false); err == nil {
+		t.Fatal("type checking")
+	}
+	if data, _, err := ReadFile(filename); err != nil || !bytes.HasSuffix(data, []byte("var _ = A\n")) {
+		t.Fatalf("%s %v", data, err)
+	}
+}
+
+func TestRenderMulti(t *testing.T) {
+	files, err := RenderMulti(test{Value: "A"}, map[string]string{
+		"a.go": "var {{ .Value }} = 1",
+		"b.go": "var {{ .Value }} = 2",
+	}, map[string]interface{}{"b.go": test{Value: "B"}}, "x")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 2 || !bytes.HasSuffix(files["a.go"], []byte("var A = 1\n")) || !bytes.HasSuffix(files["b.go"], []byte("var B = 2\n")) {
+		t.Fatalf("%s", files)
+	}
+}
+
+const testFileBuilderRet = `// Code generated by gozz:test github.com/go-zing/gozz. DO NOT EDIT.
+
+package x
+
+import (
+	"context"
+	"time"
+)
+
+var A = context.Background
+
+var B = time.Now
+`
+
+func TestFileBuilder(t *testing.T) {
+	builder := &FileBuilder{Package: "x", Imports: Imports{}}
+	for _, v := range []test{{Value: "A", MultiLine: "context"}, {Value: "B", MultiLine: "time"}} {
+		v.MultiLine = builder.Imports.Add(v.MultiLine)
+		if err := builder.Execute(v, "var {{ .Value }} = {{ .MultiLine }}.{{ if eq .Value \"A\" }}Background{{ else }}Now{{ end }}"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_ = builder.Imports.Add("context")
+	b, err := builder.Render(test{}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != testFileBuilderRet {
+		t.Fatalf("%s", b)
+	}
+}
+
+func TestRenderConfigBuildTags(t *testing.T) {
+	b, err := RenderConfig{BuildTags: []string{"linux || darwin", "!cgo"}}.Render(test{}, "var x = 1", "x")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(b, []byte("//go:build (linux || darwin) && !cgo\n// +build linux darwin\n// +build !cgo\n\n// Code generated by")) {
+		t.Fatalf("%s", b)
+	}
+	if _, err = (RenderConfig{BuildTags: []string{"linux &&"}}).Render(test{}, "", "x"); err == nil {
+		t.Fatal("invalid build tags")
+	}
+}
+
+func TestRenderConfigGoVersion(t *testing.T) {
+	b, err := RenderConfig{BuildTags: []string{"linux"}, GoVersion: "1.18"}.Render(test{}, "var x = 1", "x")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(b, []byte("//go:build linux && go1.18\n// +build linux,go1.18\n\n// Code generated by")) {
+		t.Fatalf("%s", b)
+	}
+	if _, err = (RenderConfig{GoVersion: "1.x"}).Render(test{}, "", "x"); err == nil {
+		t.Fatal("invalid go version")
+	}
+}
+
+func TestRenderConfigOmitHeader(t *testing.T) {
+	b, err := RenderConfig{OmitHeader: true, BuildTags: []string{"linux"}}.Render(test{}, "var x  = 1", "x")
+	if err != nil || string(b) != "package x\n\nvar x = 1\n" {
+		t.Fatalf("%q %v", b, err)
+	}
+	b, err = RenderConfig{OmitHeader: true, OmitPackage: true}.Render(test{}, "var  x = 1\nfunc  f() {}", "x")
+	if err != nil || string(b) != "var x = 1\n\nfunc f() {}\n" {
+		t.Fatalf("%q %v", b, err)
+	}
+	b, err = RenderConfig{OmitPackage: true}.Render(test{}, "type T  int", "x")
+	if err != nil || !bytes.HasPrefix(b, []byte("// Code generated by")) || !bytes.HasSuffix(b, []byte("\n\ntype T int\n")) || bytes.Contains(b, []byte("package")) {
+		t.Fatalf("%q %v", b, err)
+	}
+}
+
+func TestRenderConfigExternalTest(t *testing.T) {
+	b, err := RenderConfig{ExternalTest: true}.Render(test{}, "", "x")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasSuffix(b, []byte("package x_test\n")) {
+		t.Fatalf("%s", b)
+	}
+}
+
+func TestRenderScaffold(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "x.go")
+	if written, err := RenderScaffold(test{Value: "A"}, "var {{ .Value }} = 1", filename, "x"); err != nil || !written {
+		t.Fatal(written, err)
+	}
+	if written, err := RenderScaffold(test{Value: "B"}, "var {{ .Value }} = 1", filename, "x"); err != nil || written {
+		t.Fatal(written, err)
+	}
+	data, _, err := ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(data, []byte("DO NOT EDIT")) || !bytes.Contains(data, []byte("var A = 1")) {
+		t.Fatalf("%s", data)
+	}
+}
+
+func TestCleanGenerated(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "src.go"), []byte("package x\n\n// +zz:test\ntype T struct{}\n\n// +zz:other\ntype U struct{}\n"), 0o664); err != nil {
+		t.Fatal(err)
+	}
+	for name, sources := range map[string][]string{
+		"t_gen.go":       {"src.go#T"},
+		"renamed_gen.go": {"src.go#Old"},
+		"u_gen.go":       {"src.go#U"},
+		"removed_gen.go": {"removed.go#X"},
+		"multi_gen.go":   {"src.go#Old", "src.go#T"},
+		"legacy_gen.go":  nil,
+	} {
+		if err := (RenderConfig{Sources: sources}).RenderWrite(test{}, "", filepath.Join(dir, name), "x"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(dir, "edit_gen.go"), []byte("// Code generated by gozz:test github.com/go-zing/gozz.\n// Source: removed.go#X\n\npackage x\n"), 0o664); err != nil {
+		t.Fatal(err)
+	}
+
+	data, _, err := ReadFile(filepath.Join(dir, "multi_gen.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plugin, ok := GeneratedPlugin(data); !ok || plugin != "test" || strings.Join(GeneratedSources(data), ",") != "src.go#Old,src.go#T" {
+		t.Fatalf("%s", data)
+	}
+
+	stale, err := CleanGenerated(dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, filename := range stale {
+		names = append(names, filepath.Base(filename))
+	}
+	if strings.Join(names, ",") != "removed_gen.go,renamed_gen.go,u_gen.go" {
+		t.Fatal(names)
+	}
+	if _, err = CleanGenerated(dir, true); err != nil {
+		t.Fatal(err)
+	}
+	for _, filename := range stale {
+		if _, err = os.Stat(filename); !os.IsNotExist(err) {
+			t.Fatal(err)
+		}
+	}
+	if _, err = os.Stat(filepath.Join(dir, "legacy_gen.go")); err != nil {
+		t.Fatal(err)
+	}
+}
+
+type testSourcesData struct {
+	test
+	Entities DeclEntities
+	Groups   map[string][]*DeclEntity
+}
+
+func TestRenderWriteSources(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src.go")
+	if err := os.WriteFile(src, []byte("package x\n\n// +zz:test\ntype T struct{}\n\n// +zz:test\ntype U struct{}\n"), 0o664); err != nil {
+		t.Fatal(err)
+	}
+	decls, err := ParseFileDecls(src, AnnotationPrefix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entities := decls.Parse(test{}, nil)
+	if len(entities) != 2 {
+		t.Fatal(entities)
+	}
+
+	filename := filepath.Join(dir, "gen", "x_gen.go")
+	data := testSourcesData{Entities: entities[1:], Groups: map[string][]*DeclEntity{"t": {&entities[0]}}}
+	if err = RenderWrite(data, "", filename, "gen", false); err != nil {
+		t.Fatal(err)
+	}
+	b, _, err := ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sources := GeneratedSources(b); strings.Join(sources, ",") != "../src.go#T,../src.go#U" {
+		t.Fatalf("%s", b)
+	}
+	if stale, err := CleanGenerated(dir, false); err != nil || len(stale) != 0 {
+		t.Fatal(stale, err)
+	}
+
+	// annotations removed from sources
+	if err = os.WriteFile(src, []byte("package x\n\ntype T struct{}\n\ntype U struct{}\n"), 0o664); err != nil {
+		t.Fatal(err)
+	}
+	if stale, err := CleanGenerated(dir, true); err != nil || len(stale) != 1 || stale[0] != filename {
+		t.Fatal(stale, err)
+	}
+	if _, err = os.Stat(filename); !os.IsNotExist(err) {
+		t.Fatal(err)
+	}
+}
+
+func TestDirAggregatorSources(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "a.go")
+	if err := os.WriteFile(filename, []byte("package x\n\n// +zz:test\ntype A struct{}\n\n// +zz:test\ntype B struct{}\n"), 0o664); err != nil {
+		t.Fatal(err)
+	}
+	decls, err := ParseFileDecls(filename, AnnotationPrefix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	aggregators := NewDirAggregators(decls.Parse(test{}, nil))
+	if err = aggregators[0].RenderWrite(test{}, "", filepath.Join("gen", "all.go"), false); err != nil {
+		t.Fatal(err)
+	}
+	data, _, err := ReadFile(filepath.Join(dir, "gen", "all.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sources := GeneratedSources(data); strings.Join(sources, ",") != "../a.go#A,../a.go#B" {
+		t.Fatal(sources)
+	}
+	if stale, err := CleanGenerated(dir, false); err != nil || len(stale) != 0 {
+		t.Fatal(stale, err)
+	}
+}
+
+func TestTryExecuteTemplate(t *testing.T) {
+	dst := "origin"
+	if err := TryExecuteTemplate(test{}, "{{ .Nmae }}", &dst); !errors.Is(err, ErrTemplateExecute) || dst != "origin" {
+		t.Fatal(err, dst)
+	}
+	if err := TryExecuteTemplate(test{}, "{{ .Value }", &dst); err != nil || dst != "{{ .Value }" {
+		t.Fatal(err, dst)
+	}
+	dst = "origin"
+	if err := TryExecuteTemplate(test{}, "{{ .Value }}{{ end }}", &dst); !errors.Is(err, ErrTemplateParse) || dst != "origin" {
+		t.Fatal(err, dst)
+	}
+	if err := TryExecuteTemplate(test{Value: "v"}, "{{ .Value }}", &dst); err != nil || dst != "v" {
+		t.Fatal(err, dst)
+	}
+}
+
+func TestRenderDeclEntity(t *testing.T) {
+	f, err := parser.ParseFile(token.NewFileSet(), "", "package x\n\n// +zz:test:arg0:key=value\ntype T struct{}\n", parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entities := parseFileDecls(&File{Ast: f}, AnnotationPrefix)[0].parse("test", 1, nil)
+	if len(entities) != 1 {
+		t.Fatal(entities)
+	}
+	files, err := RenderMulti(test{}, map[string]string{
+		"x.go": `var {{ .Name }}{{ index .Args 0 | title }} = "{{ .Package }}:{{ .Options.Get "key" "" }}:{{ .Options.Get "none" "def" }}"`,
+	}, map[string]interface{}{"x.go": entities[0]}, "x")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasSuffix(files["x.go"], []byte(`var TArg0 = "x:value:def"`+"\n")) {
+		t.Fatalf("%s", files["x.go"])
+	}
+}
+
+func TestResolveTemplate(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "custom.tmpl")
+	if err := os.WriteFile(filename, []byte("custom"), 0o664); err != nil {
+		t.Fatal(err)
+	}
+	if text, err := ResolveTemplate(Options{}, "default"); err != nil || text != "default" {
+		t.Fatal(text, err)
+	}
+	// default root is module directory
+	if _, err := ResolveTemplate(Options{TemplateOptionKey: filename}, "default"); err == nil || !strings.Contains(err.Error(), "escapes") {
+		t.Fatal(err)
+	}
+	if _, err := ResolveTemplate(Options{TemplateOptionKey: "/etc/passwd"}, "default"); err == nil {
+		t.Fatal("escaped module directory")
+	}
+	if _, err := ResolveTemplate(Options{TemplateOptionKey: "../custom.tmpl"}, "default"); err == nil || !strings.Contains(err.Error(), "escapes") {
+		t.Fatal(err)
+	}
+	if text, err := ResolveTemplate(Options{TemplateOptionKey: "go.mod"}, "default"); err != nil || !strings.HasPrefix(text, "module") {
+		t.Fatal(text, err)
+	}
+
+	TemplateRoot = filepath.Dir(filename)
+	defer func() { TemplateRoot = "" }()
+	if text, err := ResolveTemplate(Options{TemplateOptionKey: "custom.tmpl"}, "default"); err != nil || text != "custom" {
+		t.Fatal(text, err)
+	}
+	if text, err := ResolveTemplate(Options{TemplateOptionKey: filename}, "default"); err != nil || text != "custom" {
+		t.Fatal(text, err)
+	}
+	if _, err := ResolveTemplate(Options{TemplateOptionKey: filename + ".x"}, "default"); !os.IsNotExist(errors.Unwrap(err)) {
+		t.Fatal(err)
+	}
+	if _, err := ResolveTemplate(Options{TemplateOptionKey: "/etc/passwd"}, "default"); err == nil {
+		t.Fatal("escaped template root")
+	}
+}
+
+func TestDirAggregator(t *testing.T) {
+	dir := t.TempDir()
+	for name, data := range map[string]string{
+		"b.go":     "package x\n\n// +zz:test\ntype B struct{}\n\n// +zz:test\ntype A struct{}\n",
+		"a.go":     "package x\n\n// +zz:test\ntype C struct{}\n",
+		"sub/x.go": "package sub\n\n// +zz:test\ntype D struct{}\n",
+	} {
+		filename := filepath.Join(dir, filepath.FromSlash(name))
+		_ = os.MkdirAll(filepath.Dir(filename), 0o755)
+		if err := os.WriteFile(filename, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	decls, err := ParseFileOrDirectory(dir, AnnotationPrefix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	aggregators := NewDirAggregators(decls.Parse(test{}, nil))
+	if len(aggregators) != 2 || aggregators[0].Dir != dir || aggregators[1].Package != "sub" {
+		t.Fatal(aggregators)
+	}
+	data, err := aggregators[0].Render(test{}, `var _ = {{ .Imports.Add "reflect" }}.TypeOf
+var registry = []interface{}{ {{ range .Entities }}new({{ .Name }}),{{ end }} }`, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(data, []byte("import (\n\t\"reflect\"\n)")) || !bytes.Contains(data, []byte("{new(C), new(B), new(A)}")) {
+		t.Fatalf("%s", data)
+	}
+}
+
+func TestRenderWriteEditableChecksum(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "x.go")
+	config := RenderConfig{Editable: true}
+	if err := config.RenderWrite(test{}, "var x = 1", filename, "x"); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(filename)
+	if !strings.HasPrefix(string(data), "// Code generated by gozz:test github.com/go-zing/gozz.\n// gozz:checksum ") {
+		t.Fatalf("%s", data)
+	}
+
+	// regenerate without edits
+	if err := config.RenderWrite(test{}, "var x = 2", filename, "x"); err != nil {
+		t.Fatal(err)
+	}
+
+	// user edits
+	data, _ = os.ReadFile(filename)
+	edited := append(data, "\nvar y = 1\n"...)
+	if err := os.WriteFile(filename, edited, 0o664); err != nil {
+		t.Fatal(err)
+	}
+
+	// skipped with warning
+	bf := &bytes.Buffer{}
+	defer func(logger *log.Logger) { Logger = logger }(Logger)
+	Logger = log.New(bf, "", 0)
+	if err := config.RenderWrite(test{}, "var x = 3", filename, "x"); err != nil || !strings.Contains(bf.String(), filename) {
+		t.Fatal(err, bf.String())
+	}
+	if data, _ = os.ReadFile(filename); !bytes.Equal(data, edited) {
+		t.Fatalf("%s", data)
+	}
+
+	// hard fail in strict mode
+	StrictResolution = true
+	defer func() { StrictResolution = false }()
+	if err := config.RenderWrite(test{}, "var x = 3", filename, "x"); !errors.Is(err, ErrUserEdited) {
+		t.Fatal(err)
+	}
+
+	OverwriteEdited = true
+	defer func() { OverwriteEdited = false }()
+	if err := config.RenderWrite(test{}, "var x = 3", filename, "x"); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ = os.ReadFile(filename); !bytes.HasSuffix(data, []byte("var x = 3\n")) {
+		t.Fatalf("%s", data)
+	}
+	OverwriteEdited = false
+
+	// aggregated editable file
+	aggregator := &DirAggregator{Dir: filepath.Dir(filename), Package: "x"}
+	if err := aggregator.RenderWrite(test{}, "var z = 1", "all.go", true); err != nil {
+		t.Fatal(err)
+	}
+	filename = filepath.Join(aggregator.Dir, "all.go")
+	if data, _ = os.ReadFile(filename); !strings.Contains(string(data), "\n// gozz:checksum ") {
+		t.Fatalf("%s", data)
+	}
+	if err := os.WriteFile(filename, append(data, "\nvar w = 1\n"...), 0o664); err != nil {
+		t.Fatal(err)
+	}
+	if err := aggregator.RenderWrite(test{}, "var z = 2", "all.go", true); !errors.Is(err, ErrUserEdited) {
+		t.Fatal(err)
+	}
+}
+
+func TestRenderEach(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "x.go")
+	if err := os.WriteFile(filename, []byte("package x\n\n// +zz:test\ntype A struct{}\n\n// +zz:test\ntype B struct{}\n\n// +zz:test\ntype C struct{}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	decls, err := ParseFileOrDirectory(dir, AnnotationPrefix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entities := decls.Parse(test{}, nil)
+	err = RenderEach(entities, func(entity DeclEntity) (string, []byte, error) {
+		if entity.Name() == "B" {
+			return "", nil, errors.New("bad")
+		}
+		data, err := RenderTemplate(test{}, "var _ = new("+entity.Name()+")", "x", false)
+		return filepath.Join(dir, "out", strings.ToLower(entity.Name())+".go"), data, err
+	})
+	if err == nil || !strings.Contains(err.Error(), "x.go:B: bad") {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"a", "c"} {
+		if data, e := os.ReadFile(filepath.Join(dir, "out", name+".go")); e != nil || !bytes.Contains(data, []byte("new("+strings.ToUpper(name)+")")) {
+			t.Fatal(name, e)
+		}
+	}
+}
+
+func TestRenderRaw(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "x.json")
+	if err := RenderRaw(test{Value: "v"}, `{"value":  {{ quote .Value }}}`, filename); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := os.ReadFile(filename); err != nil || string(data) != `{"value":  "v"}` {
+		t.Fatal(string(data), err)
+	}
+	if err := RenderRaw(test{}, "{{ .None }}", filename); !errors.Is(err, ErrTemplateExecute) {
+		t.Fatal(err)
+	}
+}
+
+func TestRenderTemplateData(t *testing.T) {
+	data := struct {
+		Entity  test
+		Imports Imports
+	}{Entity: test{Value: "v"}, Imports: Imports{}}
+	b, err := RenderTemplateData(test{}, &data, `var _ = {{ .Imports.Add "strings" }}.ToUpper({{ quote .Entity.Value }})`, "x", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(b, []byte("// Code generated by gozz:test")) || !bytes.HasSuffix(b, []byte("var _ = strings.ToUpper(\"v\")\n")) {
+		t.Fatalf("%s", b)
+	}
+}
@@ -18,8 +18,52 @@
 package zcore
 
 import (
+	"fmt"
 	"log"
 	"os"
+	"strings"
+	"sync"
 )
 
-var Logger = log.New(os.Stderr, "[GOZZ] ", 0)
+var (
+	Logger = log.New(os.Stderr, "[GOZZ] ", 0)
+
+	// StrictResolution treats warnings such as unresolved types or skipped unparsable files as errors.
+	// warnings would be collected and returned by TakeWarnings and PluginEntities.Run
+	StrictResolution = false
+
+	warnings   []string
+	warningsMu sync.Mutex
+)
+
+// Warnf collects warning message in strict resolution mode or else prints message with Logger
+func Warnf(format string, v ...interface{}) {
+	msg := fmt.Sprintf(format, v...)
+	if !StrictResolution {
+		Logger.Printf("warning: %s\n", msg)
+		return
+	}
+	warningsMu.Lock()
+	warnings = append(warnings, msg)
+	warningsMu.Unlock()
+}
+
+// strictWarnf collects warning message only in strict resolution mode.
+// it reports expected failures silent by default such as unresolved types or skipped files
+func strictWarnf(format string, v ...interface{}) {
+	if StrictResolution {
+		Warnf(format, v...)
+	}
+}
+
+// TakeWarnings returns collected warnings as error and resets collected warnings
+func TakeWarnings() error {
+	warningsMu.Lock()
+	defer warningsMu.Unlock()
+	if len(warnings) == 0 {
+		return nil
+	}
+	err := fmt.Errorf("%d warnings:\n%s", len(warnings), strings.Join(warnings, "\n"))
+	warnings = nil
+	return err
+}
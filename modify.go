@@ -23,6 +23,7 @@ import (
 	"go/ast"
 	"go/format"
 	"go/parser"
+	"go/scanner"
 	"go/token"
 	"os"
 	"path"
@@ -82,6 +83,20 @@ func (f *File) Lookup(name string) *ast.Object {
 	return f.Ast.Scope.Lookup(name)
 }
 
+// Rewrite is a shortcut of ModifySet to replace ast nodes data and adds imports of single file
+// then apply changes and write back to file
+func (f *File) Rewrite(edits map[ast.Node][]byte, imports Imports) error {
+	set := ModifySet{}
+	m := set.Add(f.Path)
+	for node, data := range edits {
+		m.Nodes[node] = data
+	}
+	for p, name := range imports {
+		m.Imports[p] = name
+	}
+	return set.Apply()
+}
+
 func (f *File) nodeReplacer(node ast.Node) bytesReplacer {
 	return bytesReplacer{origin: f.Node(node), offset: int(node.Pos() - 1)}
 }
@@ -105,8 +120,10 @@ func (f *File) Imports() Imports {
 }
 
 // ReplacePackages try replaces type package selector to provide node according to dst filename
-// return modified node data bytes
-func (f *File) ReplacePackages(node ast.Node, dstFilename string, dstImports Imports) (data []byte) {
+// return modified node data bytes. if dst filename is in same package of file,
+// names including unexported names would be kept unqualified even import path could not be resolved.
+// dst test file of external "_test" package in same directory is treated as different package
+func (f *File) ReplacePackages(node ast.Node, dstFilename string, dstImports ImportAdder) (data []byte) {
 	if node == nil {
 		return
 	}
@@ -117,8 +134,18 @@ func (f *File) ReplacePackages(node ast.Node, dstFilename string, dstImports Imp
 		srcImportPath: GetImportPath(f.Path),
 		dstImportPath: GetImportPath(dstFilename),
 	}
-	if len(pr.srcImportPath) == 0 || len(pr.dstImportPath) == 0 {
+	external, samePackage := isExternalTestFile(dstFilename), false
+	if !external {
+		_, samePackage = ImportPathBetween(filepath.Dir(f.Path), filepath.Dir(dstFilename))
+	}
+	if samePackage {
+		// same package output references all names directly
+		pr.dstImportPath = pr.srcImportPath
+	} else if len(pr.srcImportPath) == 0 || len(pr.dstImportPath) == 0 {
 		return nil
+	} else if external {
+		// external test package has "_test" suffix import path as TestPackage
+		pr.dstImportPath += "_test"
 	}
 	ast.Walk(pr, node)
 	return pr.Bytes()
@@ -128,7 +155,7 @@ func (f *File) ReplacePackages(node ast.Node, dstFilename string, dstImports Imp
 type packagesReplacer struct {
 	bytesReplacer
 	srcImports    Imports
-	dstImports    Imports
+	dstImports    ImportAdder
 	srcImportPath string
 	dstImportPath string
 }
@@ -213,12 +240,40 @@ func (imps Imports) add(path, as string) string {
 	return imps.add(path, as+"2")
 }
 
+// ImportAdder adds import path into imports and returns import name.
+// it is implemented by Imports and PreferredImports to qualify types for output file
+type ImportAdder interface {
+	Add(p string) (name string)
+}
+
 // Add check import path exist or adds into imports and return imports name
 func (imps Imports) Add(p string) (name string) {
+	return imps.AddAs(p, "")
+}
+
+// PreferredImports carries preferred import names with import path as key for imports of single render.
+// Add would use preferred name if it is not taken by other import path or else rotate name as AddAs
+type PreferredImports struct {
+	Imports
+	Preferred map[string]string
+}
+
+// Add check import path exist or adds into imports with preferred name and return imports name
+func (imps PreferredImports) Add(p string) (name string) {
+	return imps.AddAs(p, imps.Preferred[p])
+}
+
+// AddAs check import path exist or adds into imports with preferred name and return imports name.
+// if preferred name is empty then use base of import path.
+// if preferred name is taken by other import path then rotate name with suffix
+func (imps Imports) AddAs(p string, preferred string) (name string) {
 	if n, exist := imps[p]; exist {
 		return n
 	}
-	return imps.add(p, importNameReplacer.Replace(path.Base(p)))
+	if len(preferred) == 0 {
+		preferred = importNameReplacer.Replace(path.Base(p))
+	}
+	return imps.add(p, preferred)
 }
 
 var importNameReplacer = strings.NewReplacer("-", "", ".", "")
@@ -290,6 +345,32 @@ func (set *ModifySet) Add(filename string) *Modify {
 	return m
 }
 
+// ReplaceDecl lookups top-level declaration by name from Modify filename and adds replacement of the declaration node.
+// if the declaration is the only spec of ungrouped *ast.GenDecl, the whole *ast.GenDecl would be replaced
+// so src should contain keyword as "type" "var" "const". otherwise only the spec would be replaced
+func (m *Modify) ReplaceDecl(name string, src []byte) (err error) {
+	f, err := ParseFile(m.Filename)
+	if err != nil {
+		return
+	}
+	obj := f.Lookup(name)
+	if obj == nil {
+		return fmt.Errorf("declaration %s not found in %s", name, m.Filename)
+	}
+	node, ok := obj.Decl.(ast.Node)
+	if !ok {
+		return fmt.Errorf("declaration %s not found in %s", name, m.Filename)
+	}
+	for _, decl := range f.Ast.Decls {
+		if gd, ok := decl.(*ast.GenDecl); ok && !gd.Lparen.IsValid() && len(gd.Specs) == 1 && gd.Specs[0] == node {
+			node = gd
+			break
+		}
+	}
+	m.Nodes[node] = src
+	return
+}
+
 // applyImports to update provide data (golang file) imports and return updated data
 func (m *Modify) applyImports(data []byte) (ret []byte, err error) {
 	if len(m.Imports) == 0 {
@@ -457,3 +538,113 @@ func (r *bytesReplacer) Replace(start, end int, dst []byte) {
 	BuffPool.Put(r.updated)
 	r.updated = bf
 }
+
+// NormalizeImports assigns a consistent import name per import path across all Modify in ModifySet.
+// the name used by most files wins and ties are broken by lexical order.
+// imports already declared in exists file or names taken by other import path are kept,
+// renamed package selectors in Nodes and Appends data would be updated to new name.
+// it should be called before Apply
+func (set *ModifySet) NormalizeImports() {
+	set.mu.Lock()
+	defer set.mu.Unlock()
+
+	// count names used by each import path
+	counts := make(map[string]map[string]int)
+	for _, m := range set.set {
+		for p, name := range m.Imports {
+			if name == "." || name == "_" {
+				continue
+			}
+			if counts[p] == nil {
+				counts[p] = make(map[string]int)
+			}
+			counts[p][name]++
+		}
+	}
+
+	// pick most used name for each import path
+	preferred := make(map[string]string, len(counts))
+	for p, names := range counts {
+		for name, count := range names {
+			if pick, ok := preferred[p]; !ok || count > names[pick] || (count == names[pick] && name < pick) {
+				preferred[p] = name
+			}
+		}
+	}
+
+	for _, m := range set.set {
+		m.normalizeImports(preferred)
+	}
+}
+
+// normalizeImports renames Modify imports into preferred names if available
+func (m *Modify) normalizeImports(preferred map[string]string) {
+	// imports exist in file could not be renamed and their names could not be taken
+	pinned := make(map[string]bool)
+	var existing Imports
+	if f, err := ParseFile(m.Filename); err == nil {
+		for _, imp := range f.Ast.Imports {
+			if p, e := strconv.Unquote(imp.Path.Value); e == nil {
+				pinned[p] = true
+			}
+		}
+		existing = f.Imports()
+	}
+
+	paths := make([]string, 0, len(m.Imports))
+	for p := range m.Imports {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	renames := make(map[string]string)
+	for _, p := range paths {
+		name, to := m.Imports[p], preferred[p]
+		if len(to) == 0 || name == to || name == "." || name == "_" || pinned[p] || len(m.Imports.Which(to)) > 0 || len(existing.Which(to)) > 0 {
+			continue
+		}
+		m.Imports[p] = to
+		renames[name] = to
+	}
+	if len(renames) == 0 {
+		return
+	}
+
+	for node, data := range m.Nodes {
+		m.Nodes[node] = renameSelectors(data, renames)
+	}
+	for i, data := range m.Appends {
+		m.Appends[i] = renameSelectors(data, renames)
+	}
+}
+
+// renameSelectors scans data tokens and replaces identifiers followed by period with renames
+func renameSelectors(data []byte, renames map[string]string) []byte {
+	fileSet := token.NewFileSet()
+	file := fileSet.AddFile("", fileSet.Base(), len(data))
+
+	var s scanner.Scanner
+	s.Init(file, data, nil, 0)
+
+	replacer := &bytesReplacer{origin: data}
+	var (
+		prevTok, lastTok token.Token
+		prevPos          token.Pos
+		prevLit          string
+	)
+	for {
+		pos, tok, lit := s.Scan()
+		if tok == token.EOF {
+			break
+		}
+		// package selector should not be a selected field as x.time.Now
+		if tok == token.PERIOD && prevTok == token.IDENT && lastTok != token.PERIOD {
+			if to, ok := renames[prevLit]; ok {
+				offset := file.Offset(prevPos)
+				replacer.Replace(offset, offset+len(prevLit), []byte(to))
+			}
+		}
+		lastTok, prevTok, prevPos, prevLit = prevTok, tok, pos, lit
+	}
+	return replacer.Bytes()
+}
@@ -19,8 +19,10 @@ package zcore
 
 import (
 	"bytes"
+	"go/ast"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"testing"
 )
 
@@ -46,14 +48,14 @@ var _ = new(time.Time)
 )
 
 func TestModify(t *testing.T) {
-	_ = ioutil.WriteFile("test", []byte(testModifyData), 0o664)
-	defer os.Remove("test")
-	f, err := ParseFile("test")
+	filename := filepath.Join(t.TempDir(), "test")
+	_ = ioutil.WriteFile(filename, []byte(testModifyData), 0o664)
+	f, err := ParseFile(filename)
 	if err != nil {
 		t.Fatal(err)
 	}
 	set := ModifySet{}
-	m := set.Add("test")
+	m := set.Add(filename)
 	m.Imports = f.Imports()
 	m.Imports.Add("context")
 	m.Imports.Add("host.com/time")
@@ -61,7 +63,7 @@ func TestModify(t *testing.T) {
 	if err = m.Apply(); err != nil {
 		t.Fatal(err)
 	}
-	data, err := os.ReadFile("test")
+	data, err := os.ReadFile(filename)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -69,3 +71,170 @@ func TestModify(t *testing.T) {
 		t.Fatal()
 	}
 }
+
+func TestImportsAddAs(t *testing.T) {
+	imps := Imports{"host.com/x/errors": "xerrors"}
+	if name := imps.AddAs("github.com/pkg/errors", "pkgerrors"); name != "pkgerrors" {
+		t.Fatal(name)
+	}
+	if name := imps.AddAs("host.com/y/errors", "xerrors"); name != "xerrors2" {
+		t.Fatal(name)
+	}
+	if name := imps.AddAs("github.com/pkg/errors", "other"); name != "pkgerrors" {
+		t.Fatal(name)
+	}
+
+	preferred := PreferredImports{Imports: imps, Preferred: map[string]string{"host.com/z/errors": "zerrors", "host.com/w/errors": "pkgerrors"}}
+	if name := preferred.Add("host.com/z/errors"); name != "zerrors" || imps["host.com/z/errors"] != "zerrors" {
+		t.Fatal(name)
+	}
+	// preferred name taken
+	if name := preferred.Add("host.com/w/errors"); name != "pkgerrors2" {
+		t.Fatal(name)
+	}
+	// preferences are carried by imports of render only
+	if name := (Imports{}).Add("host.com/z/errors"); name != "errors" {
+		t.Fatal(name)
+	}
+	if name := FixPackage("errors.New", "", "", Imports{"host.com/z/errors": "errors"}, preferred); name != "zerrors.New" {
+		t.Fatal(name)
+	}
+}
+
+func TestFileRewrite(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "test")
+	_ = ioutil.WriteFile(filename, []byte(testModifyData), 0o664)
+	f, err := ParseFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	imports := f.Imports()
+	imports.Add("context")
+	imports.Add("host.com/time")
+	if err = f.Rewrite(map[ast.Node][]byte{f.Ast.Name: []byte("x")}, imports); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, []byte(testModifyRetData)) {
+		t.Fatal(string(data))
+	}
+}
+
+func TestModifyReplaceDecl(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "test")
+	_ = ioutil.WriteFile(filename, []byte("package x\n\ntype T int\n\nvar (\n\tV = 1\n)\n\nfunc F() {}\n"), 0o664)
+	set := ModifySet{}
+	m := set.Add(filename)
+	for name, src := range map[string]string{"T": "type T string", "V": "V = 2", "F": "func F() { println() }"} {
+		if err := m.ReplaceDecl(name, []byte(src)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := m.ReplaceDecl("X", nil); err == nil {
+		t.Fatal()
+	}
+	if err := set.Apply(); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(filename)
+	if string(data) != "package x\n\ntype T string\n\nvar (\n\tV = 2\n)\n\nfunc F() { println() }\n" {
+		t.Fatal(string(data))
+	}
+}
+
+func TestModifySetNormalizeImports(t *testing.T) {
+	set := ModifySet{}
+	for name, alias := range map[string]string{"a": "yaml", "b": "yaml2", "c": "yaml2", "d": "yaml", "e": "yaml2"} {
+		m := set.Add("test_normalize_" + name)
+		m.Imports["gopkg.in/yaml.v3"] = alias
+		m.Appends = append(m.Appends, []byte("var _ = "+alias+".Marshal\nvar _ = x."+alias+".Y"))
+	}
+	// preferred name taken by other import path
+	set.Add("test_normalize_d").Imports["host.com/yaml2"] = "yaml2"
+	set.NormalizeImports()
+
+	for name, expect := range map[string]string{"a": "yaml2", "b": "yaml2", "c": "yaml2", "d": "yaml", "e": "yaml2"} {
+		if n := set.Add("test_normalize_" + name).Imports["gopkg.in/yaml.v3"]; n != expect {
+			t.Fatal(name, n)
+		}
+	}
+	if data := string(set.Add("test_normalize_a").Appends[0]); data != "var _ = yaml2.Marshal\nvar _ = x.yaml.Y" {
+		t.Fatal(data)
+	}
+}
+
+func TestModifySetNormalizeImportsFileNames(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "x.go")
+	if err := os.WriteFile(filename, []byte("package x\n\nimport yaml \"host.com/other\"\n\nvar _ = yaml.X\n"), 0o664); err != nil {
+		t.Fatal(err)
+	}
+	set := ModifySet{}
+	m := set.Add(filename)
+	m.Imports["gopkg.in/yaml.v3"] = "yaml3"
+	m.Appends = append(m.Appends, []byte("var _ = yaml3.Marshal"))
+	for _, name := range []string{"a", "b"} {
+		set.Add("test_normalize_file_" + name).Imports["gopkg.in/yaml.v3"] = "yaml"
+	}
+	set.NormalizeImports()
+
+	// preferred name is taken by import of file
+	if n := m.Imports["gopkg.in/yaml.v3"]; n != "yaml3" || string(m.Appends[0]) != "var _ = yaml3.Marshal" {
+		t.Fatal(n, string(m.Appends[0]))
+	}
+}
+
+func TestFileReplacePackagesSamePackage(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "x.go")
+	if err := os.WriteFile(filename, []byte("package x\n\ntype user struct{}\n\ntype T struct {\n\tu *user\n\tU []User\n}\n"), 0o664); err != nil {
+		t.Fatal(err)
+	}
+	f, err := ParseFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	spec := f.Lookup("T").Decl.(*ast.TypeSpec)
+	imports := Imports{}
+	data := f.ReplacePackages(spec.Type, filepath.Join(dir, "x_zz_generated.go"), imports)
+	if string(data) != "struct {\n\tu *user\n\tU []User\n}" || len(imports) != 0 {
+		t.Fatalf("%s %v", data, imports)
+	}
+}
+
+func TestFileReplacePackagesExternalTest(t *testing.T) {
+	dir := t.TempDir()
+	for name, data := range map[string]string{
+		"go.mod":    "module example.com/x\n",
+		"x.go":      "package x\n\ntype T struct {\n\tU []User\n}\n\ntype User struct{}\n",
+		"a_test.go": "package x_test\n",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0o664); err != nil {
+			t.Fatal(err)
+		}
+	}
+	f, err := ParseFile(filepath.Join(dir, "x.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	spec := f.Lookup("T").Decl.(*ast.TypeSpec)
+
+	// new file in directory with external test files
+	imports := Imports{}
+	data := f.ReplacePackages(spec.Type, filepath.Join(dir, "x_zz_test.go"), imports)
+	if string(data) != "struct {\n\tU []x.User\n}" || imports["example.com/x"] != "x" {
+		t.Fatalf("%s %v", data, imports)
+	}
+
+	// exist test file of same package
+	if err = os.WriteFile(filepath.Join(dir, "b_test.go"), []byte("package x\n"), 0o664); err != nil {
+		t.Fatal(err)
+	}
+	imports = Imports{}
+	data = f.ReplacePackages(spec.Type, filepath.Join(dir, "b_test.go"), imports)
+	if string(data) != "struct {\n\tU []User\n}" || len(imports) != 0 {
+		t.Fatalf("%s %v", data, imports)
+	}
+}
@@ -20,14 +20,18 @@ package zcore
 import (
 	"bytes"
 	"fmt"
+	"go/parser"
 	"go/token"
 	"os"
 	"os/exec"
 	"path"
 	"path/filepath"
+	"runtime"
 	"strconv"
 	"strings"
 	"sync"
+	"unicode"
+	"unicode/utf8"
 )
 
 var (
@@ -38,6 +42,34 @@ var (
 	modFileCache           = new(sync.Map)
 )
 
+var (
+	// MaxConcurrentCommands limits concurrent running commands of ExecCommand.
+	// zero or negative value means unlimited
+	MaxConcurrentCommands = runtime.GOMAXPROCS(0)
+
+	// commandsRunning counts running commands guarded by commandsCond
+	commandsRunning int
+	commandsCond    = sync.NewCond(new(sync.Mutex))
+)
+
+// acquireCommand blocks until running commands less than MaxConcurrentCommands
+func acquireCommand() {
+	commandsCond.L.Lock()
+	for MaxConcurrentCommands > 0 && commandsRunning >= MaxConcurrentCommands {
+		commandsCond.Wait()
+	}
+	commandsRunning++
+	commandsCond.L.Unlock()
+}
+
+// releaseCommand marks command finished and wakes up one waiting command
+func releaseCommand() {
+	commandsCond.L.Lock()
+	commandsRunning--
+	commandsCond.L.Unlock()
+	commandsCond.Signal()
+}
+
 // loadWithStore try loads key from sync.Map or execute provided fn to store valid results
 func loadWithStore(key string, m *sync.Map, fn func() string) (r string) {
 	if v, ok := m.Load(key); ok {
@@ -63,7 +95,11 @@ func GetPackageImportDir(pkg, dir string) (output string) {
 }
 
 // ExecCommand execute command in provide directory and get stdout,stderr as string,error
+// running commands are bounded by MaxConcurrentCommands
 func ExecCommand(command, dir string) (output string, err error) {
+	acquireCommand()
+	defer releaseCommand()
+
 	stderr := &bytes.Buffer{}
 	cmd := exec.Command("sh", "-c", command)
 	cmd.Dir = dir
@@ -110,6 +146,47 @@ func GetImportName(filename string) string {
 	})
 }
 
+// PackageNameForDir returns package name used by exists golang files in directory.
+// if directory is empty or not exist, a valid package name derived from directory base would be returned
+// with exists=false. for example directory "my-lib" would be derived as "mylib"
+func PackageNameForDir(dir string) (name string, exists bool) {
+	_ = WalkDir(dir, func(filename string) error {
+		if !IsGoFile(filename) {
+			return nil
+		}
+		data, _, err := ReadFile(filename)
+		if err != nil {
+			return nil
+		}
+		f, err := parser.ParseFile(token.NewFileSet(), filename, data, parser.PackageClauseOnly)
+		if err != nil || f.Name == nil {
+			return nil
+		}
+		name, exists = f.Name.Name, true
+		return filepath.SkipDir
+	})
+	if exists {
+		return
+	}
+
+	if abs, err := filepath.Abs(dir); err == nil {
+		dir = abs
+	}
+
+	// keep only letters and digits
+	name = strings.Map(func(r rune) rune {
+		if r < utf8.RuneSelf && (unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_') {
+			return unicode.ToLower(r)
+		}
+		return -1
+	}, filepath.Base(dir))
+
+	if len(name) == 0 || !unicode.IsLetter(rune(name[0])) || token.IsKeyword(name) {
+		name = "pkg" + name
+	}
+	return
+}
+
 // GetImportName get filename or directory module import path
 // if file is not exist then return a relative calculated result from module environments
 func GetImportPath(filename string) string {
@@ -163,8 +240,75 @@ func executeWithDir(filename string, command string) (ret, dir string) {
 	return
 }
 
-// FixPackage modify or add selector package to provide name according to src and dst import module info
-func FixPackage(name, srcImportPath, dstImportPath string, srcImports, dstImports Imports) string {
+// TestPackage returns package name and import path for test file filename.
+// if external is true, "_test" suffix would be added to both package name and import path
+// then import path could be used as destination import path in FixPackage
+// to qualify references to package under test
+func TestPackage(filename string, external bool) (pkg, importPath string) {
+	pkg, importPath = GetImportName(filename), GetImportPath(filename)
+	if external {
+		pkg, importPath = pkg+"_test", importPath+"_test"
+	}
+	return
+}
+
+// ImportPathBetween returns import path of toDir package to be imported by golang file in fromDir.
+// returns empty path with samePackage=true if both directories are same package.
+// if import path of toDir could not be resolved, empty path would be returned
+func ImportPathBetween(fromDir, toDir string) (p string, samePackage bool) {
+	from, to := GetImportPath(fromDir), GetImportPath(toDir)
+	if len(to) == 0 {
+		// unresolved import path. compare directories
+		a, _ := filepath.Abs(fromDir)
+		b, _ := filepath.Abs(toDir)
+		return "", a == b
+	}
+	if from == to {
+		return "", true
+	}
+	return to, false
+}
+
+// IsTestFile check path is golang test file
+func IsTestFile(path string) bool { return strings.HasSuffix(path, "_test.go") }
+
+// isExternalTestFile checks filename is golang test file of external test package with "_test" suffix.
+// package clause is read from filename if exists or else from other test files in same directory
+func isExternalTestFile(filename string) (external bool) {
+	if !IsTestFile(filename) {
+		return false
+	}
+	packageOf := func(filename string) (string, bool) {
+		data, _, err := ReadFile(filename)
+		if err != nil {
+			return "", false
+		}
+		f, err := parser.ParseFile(token.NewFileSet(), filename, data, parser.PackageClauseOnly)
+		if err != nil || f.Name == nil {
+			return "", false
+		}
+		return f.Name.Name, true
+	}
+	if pkg, ok := packageOf(filename); ok {
+		return strings.HasSuffix(pkg, "_test")
+	}
+	_ = WalkDir(filepath.Dir(filename), func(filename string) error {
+		if !IsTestFile(filename) {
+			return nil
+		}
+		if pkg, ok := packageOf(filename); ok && strings.HasSuffix(pkg, "_test") {
+			external = true
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	return
+}
+
+// FixPackage modify or add selector package to provide name according to src and dst import module info.
+// if src and dst import path are same, names are emitted unqualified including unexported names
+// and selectors of dst package would be removed
+func FixPackage(name, srcImportPath, dstImportPath string, srcImports Imports, dstImports ImportAdder) string {
 	name, ok := TrimPrefix(name, "*")
 	ptr := ""
 	if ok {
@@ -18,8 +18,12 @@
 package zcore
 
 import (
+	"os"
 	"path/filepath"
 	"reflect"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
 )
 
@@ -44,3 +48,90 @@ func TestGetImportName(t *testing.T) {
 		t.Fatal(ret)
 	}
 }
+
+func TestPackageNameForDir(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "my-lib.v2")
+	if name, exists := PackageNameForDir(dir); name != "mylibv2" || exists {
+		t.Fatal(name, exists)
+	}
+	if err := os.MkdirAll(dir, 0o775); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "x.go"), []byte("package lib\n"), 0o664); err != nil {
+		t.Fatal(err)
+	}
+	if name, exists := PackageNameForDir(dir); name != "lib" || !exists {
+		t.Fatal(name, exists)
+	}
+	if name, _ := PackageNameForDir(filepath.Join(dir, "1-type")); name != "pkg1type" {
+		t.Fatal(name)
+	}
+}
+
+func TestTestPackage(t *testing.T) {
+	name, p := TestPackage("x_test.go", true)
+	if name != "zcore_test" || p != pkg+"_test" {
+		t.Fatal(name, p)
+	}
+	if ret := FixPackage("*File", pkg, p, Imports{}, Imports{}); ret != "*gozzcore.File" {
+		t.Fatal(ret)
+	}
+	if name, p = TestPackage("x_test.go", false); name != "zcore" || p != pkg {
+		t.Fatal(name, p)
+	}
+}
+
+func TestFixPackageSamePackage(t *testing.T) {
+	srcImports := Imports{"host.com/x": "x", "time": "time"}
+	for name, want := range map[string]string{
+		"*user":     "*user",
+		"User":      "User",
+		"x.user":    "user",
+		"*x.User":   "*User",
+		"time.Time": "time.Time",
+	} {
+		if ret := FixPackage(name, "host.com/x", "host.com/x", srcImports, Imports{}); ret != want {
+			t.Fatal(name, ret)
+		}
+	}
+}
+
+func TestImportPathBetween(t *testing.T) {
+	if p, same := ImportPathBetween(".", "."); !same || len(p) != 0 {
+		t.Fatal(p, same)
+	}
+	if p, same := ImportPathBetween(".", filepath.Join("internal", "x")); same || p != pkg+"/internal/x" {
+		t.Fatal(p, same)
+	}
+}
+
+func TestMaxConcurrentCommands(t *testing.T) {
+	defer func(n int) { MaxConcurrentCommands = n }(MaxConcurrentCommands)
+	MaxConcurrentCommands = 2
+
+	dir, logFile := t.TempDir(), filepath.Join(t.TempDir(), "log")
+	var wg sync.WaitGroup
+	for i := 0; i < 6; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// count concurrent running commands by marker files
+			_, _ = ExecCommand("f=$(mktemp -p .); n=$(ls | wc -l); sleep 0.05; rm $f; echo $n >> "+strconv.Quote(logFile), dir)
+		}()
+	}
+	wg.Wait()
+
+	data, err := os.ReadFile(logFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	counts := strings.Fields(string(data))
+	if len(counts) != 6 {
+		t.Fatal(counts)
+	}
+	for _, n := range counts {
+		if v, _ := strconv.Atoi(n); v < 1 || v > 2 {
+			t.Fatal(counts)
+		}
+	}
+}
@@ -19,16 +19,54 @@ package zcore
 
 import (
 	"database/sql"
+	"fmt"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
 )
 
-// ormSchemaDriverRegistry provides simple registry store for all registered driver with name
-var ormSchemaDriverRegistry = make(map[string]OrmSchemaDriver)
+var (
+	// ormSchemaDriverRegistry provides simple registry store for all registered driver with name
+	ormSchemaDriverRegistry = make(map[string]OrmSchemaDriver)
+	// ormSchemaDriverRegistryMu guards ormSchemaDriverRegistry for concurrent registrations
+	ormSchemaDriverRegistryMu sync.RWMutex
+)
 
 // RegisterOrmSchemaDriver registers OrmSchemaDriver to ormSchemaDriverRegistry
-func RegisterOrmSchemaDriver(driver OrmSchemaDriver) { ormSchemaDriverRegistry[driver.Name()] = driver }
+func RegisterOrmSchemaDriver(driver OrmSchemaDriver) {
+	ormSchemaDriverRegistryMu.Lock()
+	ormSchemaDriverRegistry[driver.Name()] = driver
+	ormSchemaDriverRegistryMu.Unlock()
+}
+
+// UnregisterOrmSchemaDriver removes registered OrmSchemaDriver from ormSchemaDriverRegistry by name
+func UnregisterOrmSchemaDriver(name string) {
+	ormSchemaDriverRegistryMu.Lock()
+	delete(ormSchemaDriverRegistry, name)
+	ormSchemaDriverRegistryMu.Unlock()
+}
 
 // GetOrmSchemaDriver get OrmSchemaDriver from ormSchemaDriverRegistry by name
-func GetOrmSchemaDriver(name string) OrmSchemaDriver { return ormSchemaDriverRegistry[name] }
+func GetOrmSchemaDriver(name string) OrmSchemaDriver {
+	ormSchemaDriverRegistryMu.RLock()
+	defer ormSchemaDriverRegistryMu.RUnlock()
+	return ormSchemaDriverRegistry[name]
+}
+
+// OrmSchemaDriverRegistry returns snapshot of all registered drivers with name
+func OrmSchemaDriverRegistry() map[string]OrmSchemaDriver {
+	ormSchemaDriverRegistryMu.RLock()
+	defer ormSchemaDriverRegistryMu.RUnlock()
+	m := make(map[string]OrmSchemaDriver, len(ormSchemaDriverRegistry))
+	for name, driver := range ormSchemaDriverRegistry {
+		m[name] = driver
+	}
+	return m
+}
 
 type (
 	// OrmSchemaDriver represents interface to register as driver.
@@ -44,27 +82,349 @@ type (
 		Parse(dsn, schema, table string, types map[string]string, options Options) (tables []OrmTable, err error)
 	}
 
+	// OrmDsnBuilder represents optional interface of OrmSchemaDriver to build dsn from connection params
+	// then non-local database could be connected. Dsn(password) should be kept as localhost defaults
+	OrmDsnBuilder interface {
+		DsnFrom(params OrmDsnParams) (dsn string)
+	}
+
+	// OrmDsnParams represents database connection params to build dsn.
+	// empty fields should be filled with driver defaults
+	OrmDsnParams struct {
+		Host     string
+		Port     string
+		User     string
+		Password string
+		Database string
+		Params   map[string]string // extra driver params such as "charset" or "sslmode"
+	}
+
+	// OrmSchemaLister represents optional interface of OrmSchemaDriver to list available schemas from dsn
+	OrmSchemaLister interface {
+		Schemas(dsn string) (schemas []string, err error)
+	}
+
 	OrmTable struct {
-		Name    string
-		Table   string
-		Schema  string
-		Comment string
-		Primary string
-		Columns []OrmColumn
-		Ext     interface{}
+		Name      string
+		Table     string
+		Schema    string
+		TableType string // OrmTableTypeBase or OrmTableTypeView. view usually has empty Primary
+		Comment   string
+		Primary   string
+		Columns   []OrmColumn
+		Ext       interface{}
 	}
 
 	OrmColumn struct {
 		Name          string
 		Type          string
 		Column        string
+		DataType      string // sql data type such as "decimal"
+		ColumnType    string // sql column type with precision or members such as "decimal(10,2)"
 		Comment       string
 		Nullable      bool
 		MaximumLength int64
+		AutoIncrement bool
+		Generated     bool
+		EnumValues    []string // members of enum or set column
 		Ext           interface{}
 	}
 )
 
+// DocComment returns table comment as golang doc comment lines or empty if no comment.
+// use in template above generated struct like
+//
+//	{{ .DocComment }}
+//	type {{ .Name }} struct {
+//
+// which is equivalent to {{ comment .Comment }} with unsafe characters stripped
+func (table OrmTable) DocComment() string { return ormDocComment(table.Comment) }
+
+// DocComment returns column comment as golang doc comment lines or empty if no comment.
+// see OrmTable.DocComment
+func (column OrmColumn) DocComment() string { return ormDocComment(column.Comment) }
+
+// ormDocComment normalizes line breaks, strips control characters
+// and surrounding empty lines of comment then formats as CommentLines
+func ormDocComment(comment string) string {
+	comment = strings.NewReplacer("\r\n", "\n", "\r", "\n", "\u2028", "\n", "\u2029", "\n").Replace(comment)
+	comment = strings.Map(func(r rune) rune {
+		if r == '\n' || r == '\t' {
+			return r
+		}
+		if unicode.IsControl(r) || r == '\uFEFF' {
+			return -1
+		}
+		return r
+	}, comment)
+
+	lines := strings.Split(comment, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRightFunc(line, unicode.IsSpace)
+	}
+	if comment = strings.Trim(strings.Join(lines, "\n"), "\n"); len(strings.TrimSpace(comment)) == 0 {
+		return ""
+	}
+	return CommentLines(comment)
+}
+
+// AllocateColumnNames makes columns names unique valid golang identifiers in columns order with NameAllocator.
+// it is called by ParseOrmTables after driver parsed to avoid duplicated struct fields
+// from columns like "user_id" and "userId"
+func (table *OrmTable) AllocateColumnNames() {
+	allocator := NameAllocator{}
+	for i := range table.Columns {
+		table.Columns[i].Name = allocator.Allocate(table.Columns[i].Name)
+	}
+}
+
+// OrmDsn builds dsn of driver from params if driver implements OrmDsnBuilder
+// or else returns localhost default dsn from driver.Dsn with params password
+func OrmDsn(driver OrmSchemaDriver, params OrmDsnParams) string {
+	if builder, ok := driver.(OrmDsnBuilder); ok {
+		return builder.DsnFrom(params)
+	}
+	return driver.Dsn(params.Password)
+}
+
+// Query encodes extra params as url query sorted by key like "charset=utf8mb4&parseTime=true"
+func (params OrmDsnParams) Query() string {
+	values := make(url.Values, len(params.Params))
+	for k, v := range params.Params {
+		values.Set(k, v)
+	}
+	return values.Encode()
+}
+
+// Table types of information_schema.TABLES
+const (
+	OrmTableTypeBase = "BASE TABLE"
+	OrmTableTypeView = "VIEW"
+)
+
+// OrmViewsOption is option key of OrmSchemaDriver.Parse to include views besides base tables
+const OrmViewsOption = "views"
+
+// OrmTableTypes returns table types should be parsed by drivers according to OrmViewsOption
+func OrmTableTypes(options Options) []string {
+	if options.Exist(OrmViewsOption) {
+		return []string{OrmTableTypeBase, OrmTableTypeView}
+	}
+	return []string{OrmTableTypeBase}
+}
+
+// IsView checks table is database view which is read-only and may have no primary key
+func (table OrmTable) IsView() bool { return strings.EqualFold(table.TableType, OrmTableTypeView) }
+
+// Options keys of OrmSchemaDriver.Parse to retry database connection.
+// retry is disabled by default so connection failure would be returned immediately
+const (
+	OrmRetryOption         = "retry"          // max connection attempts such as "5"
+	OrmRetryIntervalOption = "retry_interval" // first retry interval doubled after each attempt such as "500ms". default 1s
+)
+
+// OrmRetry calls connect until it succeeds or attempts of OrmRetryOption exhausted.
+// interval of OrmRetryIntervalOption would be doubled after each failed attempt.
+// returns last error of connect
+func OrmRetry(options Options, connect func() error) (err error) {
+	attempts := 1
+	if str := options.Get(OrmRetryOption, ""); len(str) > 0 {
+		if attempts, err = strconv.Atoi(str); err != nil || attempts < 1 {
+			return fmt.Errorf("invalid %s option %q", OrmRetryOption, str)
+		}
+	}
+	interval, err := time.ParseDuration(options.Get(OrmRetryIntervalOption, "1s"))
+	if err != nil {
+		return fmt.Errorf("invalid %s option: %w", OrmRetryIntervalOption, err)
+	}
+
+	for i := 1; ; i++ {
+		if err = connect(); err == nil || i >= attempts {
+			return
+		}
+		Logger.Printf("connect failed (%d/%d): %v. retry in %s\n", i, attempts, err, interval)
+		time.Sleep(interval)
+		interval *= 2
+	}
+}
+
+// OpenOrmSqlDB opens *sql.DB and pings database with OrmRetry.
+// drivers could use it before issuing introspection queries to wait database ready
+func OpenOrmSqlDB(driverName, dsn string, options Options) (db *sql.DB, err error) {
+	if db, err = sql.Open(driverName, dsn); err != nil {
+		return
+	}
+	if err = OrmRetry(options, db.Ping); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	return
+}
+
+// ParseSqlEnumValues parse members of enum or set column type such as "enum('a','b','c')".
+// returns nil if column type is not enum or set
+func ParseSqlEnumValues(columnType string) (values []string) {
+	str := strings.TrimSpace(columnType)
+	lower := strings.ToLower(str)
+	if !(strings.HasPrefix(lower, "enum(") || strings.HasPrefix(lower, "set(")) || !strings.HasSuffix(str, ")") {
+		return
+	}
+	str = str[strings.Index(str, "(")+1 : len(str)-1]
+
+	var (
+		value  []rune
+		quoted bool
+		runes  = []rune(str)
+	)
+
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case !quoted:
+			// only quote starts member and others like "," or spaces are ignored
+			if r == '\'' {
+				quoted, value = true, value[:0]
+			}
+		case r == '\\' && i+1 < len(runes):
+			// backslash escaped
+			i++
+			value = append(value, runes[i])
+		case r == '\'' && i+1 < len(runes) && runes[i+1] == '\'':
+			// doubled quote escaped
+			i++
+			value = append(value, r)
+		case r == '\'':
+			quoted = false
+			values = append(values, string(value))
+		default:
+			value = append(value, r)
+		}
+	}
+	return
+}
+
+// SetExtra set column flags from information_schema.COLUMNS.EXTRA value
+// such as "auto_increment" or "STORED GENERATED" and "VIRTUAL GENERATED".
+// "DEFAULT_GENERATED" represents default value expression and would not be treated as generated column
+func (column *OrmColumn) SetExtra(extra string) {
+	for _, field := range strings.Fields(strings.ToUpper(extra)) {
+		switch field {
+		case "AUTO_INCREMENT":
+			column.AutoIncrement = true
+		case "GENERATED":
+			column.Generated = true
+		}
+	}
+}
+
+// ListOrmSchemas lists available schemas from dsn if driver implements OrmSchemaLister else returns nothing
+func ListOrmSchemas(driver OrmSchemaDriver, dsn string) (schemas []string, err error) {
+	if lister, ok := driver.(OrmSchemaLister); ok {
+		return lister.Schemas(dsn)
+	}
+	return
+}
+
+// QuerySqlSchemas query available schemas names from information_schema.SCHEMATA.
+// drivers could use it to implement OrmSchemaLister with opened *sql.DB
+func QuerySqlSchemas(db *sql.DB) (schemas []string, err error) {
+	rows, err := db.Query("SELECT SCHEMA_NAME FROM information_schema.SCHEMATA ORDER BY SCHEMA_NAME")
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var schema string
+		if err = rows.Scan(&schema); err != nil {
+			return
+		}
+		schemas = append(schemas, schema)
+	}
+	return schemas, rows.Err()
+}
+
+// TableTransformer transforms parsed tables before generation
+// such as dropping audit columns, renaming tables or adding synthetic fields
+type TableTransformer func(tables []OrmTable) []OrmTable
+
+var (
+	// tableTransformers provides registered TableTransformer chain applied in registered order
+	tableTransformers []TableTransformer
+	// tableTransformersMu guards tableTransformers for concurrent registrations
+	tableTransformersMu sync.RWMutex
+)
+
+// RegisterTableTransformer appends TableTransformer into transformers chain
+func RegisterTableTransformer(transformer TableTransformer) {
+	tableTransformersMu.Lock()
+	tableTransformers = append(tableTransformers, transformer)
+	tableTransformersMu.Unlock()
+}
+
+// ParseOrmTables parses tables by driver and then applies registered TableTransformer chain
+// and provided transformers in order
+func ParseOrmTables(driver OrmSchemaDriver, dsn, schema, table string, types map[string]string, options Options, transformers ...TableTransformer) (tables []OrmTable, err error) {
+	if tables, err = driver.Parse(dsn, schema, table, types, options); err != nil {
+		return
+	}
+
+	for i := range tables {
+		tables[i].AllocateColumnNames()
+	}
+
+	tableTransformersMu.RLock()
+	chain := append(append([]TableTransformer(nil), tableTransformers...), transformers...)
+	tableTransformersMu.RUnlock()
+
+	for _, transformer := range chain {
+		tables = transformer(tables)
+	}
+	return
+}
+
+// OrmTypeResolver resolves golang type from column with logic such as precision and scale of column type
+type OrmTypeResolver func(column OrmColumn) (goType string, ok bool)
+
+var (
+	// ormTypeResolvers provides registered OrmTypeResolver chain consulted in registered order
+	ormTypeResolvers []OrmTypeResolver
+	// ormTypeResolversMu guards ormTypeResolvers for concurrent registrations
+	ormTypeResolversMu sync.RWMutex
+)
+
+// RegisterOrmTypeResolver appends OrmTypeResolver into resolvers chain
+func RegisterOrmTypeResolver(resolver OrmTypeResolver) {
+	ormTypeResolversMu.Lock()
+	ormTypeResolvers = append(ormTypeResolvers, resolver)
+	ormTypeResolversMu.Unlock()
+}
+
+// ResolveOrmType resolves golang type of column by registered OrmTypeResolver chain first.
+// then lookup types mapping with column DataType as key and "*" prefixed key for nullable column.
+// returns def if no matched
+func ResolveOrmType(column OrmColumn, types map[string]string, def string) string {
+	ormTypeResolversMu.RLock()
+	resolvers := ormTypeResolvers
+	ormTypeResolversMu.RUnlock()
+
+	for _, resolver := range resolvers {
+		if typ, ok := resolver(column); ok {
+			return typ
+		}
+	}
+
+	key := strings.ToLower(column.DataType)
+	if column.Nullable {
+		if typ, ok := types["*"+key]; ok {
+			return typ
+		}
+	}
+	if typ, ok := types[key]; ok {
+		return typ
+	}
+	return def
+}
+
 // OrmTypeMapping provides default type mapping from sql datatype and golang type
 func OrmTypeMapping() map[string]string {
 	return map[string]string{
@@ -147,3 +507,57 @@ func ScanSqlRows(rows *sql.Rows, fields []string, iterator Iterator) (err error)
 	})
 	return
 }
+
+// ScanSqlRowsFunc scan sql.Rows one row at a time into OrmFieldMapper allocated by newElem
+// then invoke onRow with scanned element. it stops on first scan or onRow error.
+// rows would not be materialized so it suits streaming large result sets
+func ScanSqlRowsFunc(rows *sql.Rows, fields []string, newElem func() OrmFieldMapper, onRow func(OrmFieldMapper) error) (err error) {
+	values := make([]interface{}, len(fields))
+	mapping := make(map[string]interface{}, len(fields))
+	for rows.Next() {
+		m := newElem()
+		m.FieldMapping(mapping)
+		for i, field := range fields {
+			values[i] = mapping[field]
+		}
+		if err = rows.Scan(values...); err != nil {
+			return
+		}
+		if err = onRow(m); err != nil {
+			return
+		}
+	}
+	return rows.Err()
+}
+
+// ScanRowsToMaps scan all sql.Rows values into maps with column name as key.
+// value types are chosen by sql.ColumnType ScanType of driver or interface{} if unknown
+func ScanRowsToMaps(rows *sql.Rows) (ret []map[string]interface{}, err error) {
+	columns, err := rows.ColumnTypes()
+	if err != nil {
+		return
+	}
+
+	values := make([]interface{}, len(columns))
+	for rows.Next() {
+		// alloc new values for each row
+		for i, column := range columns {
+			if typ := column.ScanType(); typ != nil && typ != reflect.TypeOf(sql.RawBytes{}) {
+				values[i] = reflect.New(typ).Interface()
+			} else {
+				values[i] = new(interface{})
+			}
+		}
+
+		if err = rows.Scan(values...); err != nil {
+			return
+		}
+
+		m := make(map[string]interface{}, len(columns))
+		for i, column := range columns {
+			m[column.Name()] = reflect.ValueOf(values[i]).Elem().Interface()
+		}
+		ret = append(ret, m)
+	}
+	return ret, rows.Err()
+}
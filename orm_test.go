@@ -0,0 +1,510 @@
+/*
+ * Copyright (c) 2023 Maple Wu <justmaplewu@gmail.com>
+ *   National Electronics and Computer Technology Center, Thailand
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package zcore
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestOrmColumnSetExtra(t *testing.T) {
+	for extra, want := range map[string][2]bool{
+		"auto_increment":    {true, false},
+		"STORED GENERATED":  {false, true},
+		"VIRTUAL GENERATED": {false, true},
+		"DEFAULT_GENERATED on update CURRENT_TIMESTAMP": {false, false},
+		"": {false, false},
+	} {
+		column := &OrmColumn{}
+		if column.SetExtra(extra); column.AutoIncrement != want[0] || column.Generated != want[1] {
+			t.Fatal(extra, column.AutoIncrement, column.Generated)
+		}
+	}
+}
+
+func TestResolveOrmType(t *testing.T) {
+	types := OrmTypeMapping()
+	if typ := ResolveOrmType(OrmColumn{DataType: "varchar", Nullable: true}, types, "string"); typ != "sql.NullString" {
+		t.Fatal(typ)
+	}
+	if typ := ResolveOrmType(OrmColumn{DataType: "double", Nullable: true}, types, "string"); typ != "float64" {
+		t.Fatal(typ)
+	}
+	if typ := ResolveOrmType(OrmColumn{DataType: "geometry"}, types, "[]byte"); typ != "[]byte" {
+		t.Fatal(typ)
+	}
+
+	defer func(resolvers []OrmTypeResolver) { ormTypeResolvers = resolvers }(ormTypeResolvers)
+	RegisterOrmTypeResolver(func(column OrmColumn) (string, bool) {
+		return "int64", column.ColumnType == "decimal(10,0)"
+	})
+	if typ := ResolveOrmType(OrmColumn{DataType: "decimal", ColumnType: "decimal(10,0)"}, types, ""); typ != "int64" {
+		t.Fatal(typ)
+	}
+	if typ := ResolveOrmType(OrmColumn{DataType: "decimal", ColumnType: "decimal(10,2)"}, types, ""); typ != "float64" {
+		t.Fatal(typ)
+	}
+}
+
+func TestParseSqlEnumValues(t *testing.T) {
+	for columnType, want := range map[string]string{
+		"enum('a','b','c')":       "a|b|c",
+		"SET('x', 'y,z')":         "x|y,z",
+		`enum('it''s','a\'b','')`: "it's|a'b|",
+		"varchar(255)":            "",
+		"enum()":                  "",
+	} {
+		if values := ParseSqlEnumValues(columnType); strings.Join(values, "|") != want {
+			t.Fatal(columnType, values)
+		}
+	}
+}
+
+type testOrmDriver struct{}
+
+func (testOrmDriver) Name() string { return "test" }
+
+func (testOrmDriver) Dsn(password string) string { return "root:" + password + "@localhost" }
+
+func (testOrmDriver) Parse(string, string, string, map[string]string, Options) ([]OrmTable, error) {
+	return nil, nil
+}
+
+type testOrmDsnBuilder struct{ testOrmDriver }
+
+func (testOrmDsnBuilder) DsnFrom(params OrmDsnParams) string {
+	return params.User + ":" + params.Password + "@" + params.Host + ":" + params.Port + "/" + params.Database + "?" + params.Query()
+}
+
+type testOrmNamedDriver struct {
+	testOrmDriver
+	name string
+}
+
+func (d testOrmNamedDriver) Name() string { return d.name }
+
+func TestUnregisterOrmSchemaDriver(t *testing.T) {
+	name := "test-unregister"
+	defer UnregisterOrmSchemaDriver(name)
+
+	RegisterOrmSchemaDriver(testOrmNamedDriver{name: name})
+	if GetOrmSchemaDriver(name) == nil {
+		t.Fatal("registered")
+	}
+	UnregisterOrmSchemaDriver(name)
+	if GetOrmSchemaDriver(name) != nil {
+		t.Fatal("unregistered")
+	}
+
+	// unknown name
+	size := len(OrmSchemaDriverRegistry())
+	UnregisterOrmSchemaDriver("test-unknown")
+	if len(OrmSchemaDriverRegistry()) != size {
+		t.Fatal("unknown name")
+	}
+
+	// register again
+	RegisterOrmSchemaDriver(testOrmNamedDriver{name: name})
+	if driver := GetOrmSchemaDriver(name); driver == nil || driver.Name() != name {
+		t.Fatal("registered again")
+	}
+}
+
+func TestOrmSchemaDriverRegistrySnapshot(t *testing.T) {
+	name := "test-snapshot"
+	RegisterOrmSchemaDriver(testOrmNamedDriver{name: name})
+	defer UnregisterOrmSchemaDriver(name)
+
+	m := OrmSchemaDriverRegistry()
+	delete(m, name)
+	m["test-injected"] = testOrmDriver{}
+	if r := OrmSchemaDriverRegistry(); r[name] == nil || r["test-injected"] != nil {
+		t.Fatal(r)
+	}
+}
+
+func TestOrmSchemaDriverRegistryConcurrent(t *testing.T) {
+	wg := sync.WaitGroup{}
+	for i := 0; i < 8; i++ {
+		name := fmt.Sprintf("test-concurrent-%d", i)
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			RegisterOrmSchemaDriver(testOrmNamedDriver{name: name})
+		}()
+		go func() {
+			defer wg.Done()
+			_ = len(OrmSchemaDriverRegistry())
+			_ = GetOrmSchemaDriver(name)
+		}()
+	}
+	wg.Wait()
+	for i := 0; i < 8; i++ {
+		name := fmt.Sprintf("test-concurrent-%d", i)
+		if GetOrmSchemaDriver(name) == nil {
+			t.Fatal(name)
+		}
+		UnregisterOrmSchemaDriver(name)
+	}
+}
+
+func TestOrmDsn(t *testing.T) {
+	params := OrmDsnParams{Host: "db", Port: "3306", User: "u", Password: "p", Database: "x",
+		Params: map[string]string{"parseTime": "true", "charset": "utf8mb4"}}
+	if dsn := OrmDsn(testOrmDriver{}, params); dsn != "root:p@localhost" {
+		t.Fatal(dsn)
+	}
+	if dsn := OrmDsn(testOrmDsnBuilder{}, params); dsn != "u:p@db:3306/x?charset=utf8mb4&parseTime=true" {
+		t.Fatal(dsn)
+	}
+}
+
+func TestOrmDocComment(t *testing.T) {
+	for comment, want := range map[string]string{
+		"":                            "",
+		" \r\n ":                      "",
+		"user name":                   "// user name",
+		"\nline1 \r\nline2\x00\x1b\n": "// line1\n// line2",
+		"a */ b\u2028c":               "// a */ b\n// c",
+	} {
+		if ret := (OrmColumn{Comment: comment}).DocComment(); ret != want {
+			t.Fatalf("%q %q", comment, ret)
+		}
+	}
+	if ret := (OrmTable{Comment: "users"}).DocComment(); ret != "// users" {
+		t.Fatal(ret)
+	}
+}
+
+func TestOrmTableAllocateColumnNames(t *testing.T) {
+	table := OrmTable{Columns: []OrmColumn{{Name: "UserId", Column: "user_id"}, {Name: "UserId", Column: "userId"}, {Name: "Name"}}}
+	table.AllocateColumnNames()
+	if table.Columns[0].Name != "UserId" || table.Columns[1].Name != "UserId_2" || table.Columns[2].Name != "Name" {
+		t.Fatal(table.Columns)
+	}
+}
+
+func TestOrmTableTypes(t *testing.T) {
+	if types := OrmTableTypes(nil); len(types) != 1 || types[0] != OrmTableTypeBase {
+		t.Fatal(types)
+	}
+	if types := OrmTableTypes(Options{OrmViewsOption: ""}); len(types) != 2 || types[1] != OrmTableTypeView {
+		t.Fatal(types)
+	}
+	if !(OrmTable{TableType: "view"}).IsView() || (OrmTable{TableType: OrmTableTypeBase}).IsView() {
+		t.Fatal()
+	}
+}
+
+type testOrmTablesDriver struct{ testOrmDriver }
+
+func (testOrmTablesDriver) Parse(string, string, string, map[string]string, Options) ([]OrmTable, error) {
+	return []OrmTable{{Name: "User", Columns: []OrmColumn{
+		{Name: "Id", Column: "id"}, {Name: "CreatedAt", Column: "created_at"},
+		{Name: "UserId", Column: "user_id"}, {Name: "UserId", Column: "userId"},
+	}}}, nil
+}
+
+func TestParseOrmTables(t *testing.T) {
+	RegisterTableTransformer(func(tables []OrmTable) []OrmTable {
+		for i, table := range tables {
+			columns := make([]OrmColumn, 0)
+			for _, column := range table.Columns {
+				if column.Column != "created_at" {
+					columns = append(columns, column)
+				}
+			}
+			tables[i].Columns = columns
+		}
+		return tables
+	})
+	defer func() { tableTransformers = nil }()
+
+	tables, err := ParseOrmTables(testOrmTablesDriver{}, "", "", "", nil, nil, func(tables []OrmTable) []OrmTable {
+		return append(tables, OrmTable{Name: "Synthetic"})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tables) != 2 || len(tables[0].Columns) != 3 || tables[1].Name != "Synthetic" {
+		t.Fatal(tables)
+	}
+	if columns := tables[0].Columns; columns[1].Name != "UserId" || columns[2].Name != "UserId_2" {
+		t.Fatal(columns)
+	}
+}
+
+func TestOrmRetry(t *testing.T) {
+	count := 0
+	connect := func() error {
+		if count++; count < 3 {
+			return errors.New("connection refused")
+		}
+		return nil
+	}
+	if err := OrmRetry(Options{}, connect); err == nil || count != 1 {
+		t.Fatal(err, count)
+	}
+	count = 0
+	if err := OrmRetry(Options{OrmRetryOption: "3", OrmRetryIntervalOption: "1ms"}, connect); err != nil || count != 3 {
+		t.Fatal(err, count)
+	}
+	if err := OrmRetry(Options{OrmRetryOption: "0"}, connect); err == nil {
+		t.Fatal("invalid attempts")
+	}
+}
+
+// testSqlResult is result set returned by testSqlDriver for any query of dsn
+type testSqlResult struct {
+	columns []string
+	types   []reflect.Type
+	rows    [][]driver.Value
+	err     error // returned after all rows iterated
+}
+
+// testSqlResults provides registered result sets with dsn as key
+var testSqlResults sync.Map
+
+var (
+	testSqlDriverOnce sync.Once
+	testSqlDsnCount   int64
+)
+
+// openTestSql registers result as dsn and opens *sql.DB with testSqlDriver
+func openTestSql(t *testing.T, result testSqlResult) *sql.DB {
+	testSqlDriverOnce.Do(func() { sql.Register("zcoretest", testSqlDriver{}) })
+	dsn := fmt.Sprintf("%s#%d", t.Name(), atomic.AddInt64(&testSqlDsnCount, 1))
+	testSqlResults.Store(dsn, result)
+	db, err := sql.Open("zcoretest", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = db.Close()
+		testSqlResults.Delete(dsn)
+	})
+	return db
+}
+
+// testSqlDriver implements minimal database/sql/driver returning registered result set
+type testSqlDriver struct{}
+
+func (testSqlDriver) Open(dsn string) (driver.Conn, error) { return testSqlConn(dsn), nil }
+
+type testSqlConn string
+
+func (c testSqlConn) Prepare(string) (driver.Stmt, error) { return testSqlStmt(c), nil }
+
+func (testSqlConn) Close() error { return nil }
+
+func (testSqlConn) Begin() (driver.Tx, error) { return nil, errors.New("not supported") }
+
+type testSqlStmt string
+
+func (testSqlStmt) Close() error { return nil }
+
+func (testSqlStmt) NumInput() int { return -1 }
+
+func (testSqlStmt) Exec([]driver.Value) (driver.Result, error) {
+	return nil, errors.New("not supported")
+}
+
+func (s testSqlStmt) Query([]driver.Value) (driver.Rows, error) {
+	v, ok := testSqlResults.Load(string(s))
+	if !ok {
+		return nil, fmt.Errorf("unknown dsn %q", s)
+	}
+	return &testSqlRows{testSqlResult: v.(testSqlResult)}, nil
+}
+
+type testSqlRows struct {
+	testSqlResult
+	index   int
+	buffers [][]byte // reused for []byte values of each row like drivers reading from connection
+}
+
+func (r *testSqlRows) Columns() []string { return r.columns }
+
+func (r *testSqlRows) Close() error { return nil }
+
+func (r *testSqlRows) ColumnTypeScanType(index int) reflect.Type {
+	if index < len(r.types) {
+		return r.types[index]
+	}
+	return nil
+}
+
+func (r *testSqlRows) Next(dest []driver.Value) error {
+	if r.index >= len(r.rows) {
+		if r.err != nil {
+			return r.err
+		}
+		return io.EOF
+	}
+	if r.buffers == nil {
+		r.buffers = make([][]byte, len(r.columns))
+	}
+	for i, v := range r.rows[r.index] {
+		if b, ok := v.([]byte); ok {
+			r.buffers[i] = append(r.buffers[i][:0], b...)
+			v = r.buffers[i]
+		}
+		dest[i] = v
+	}
+	r.index++
+	return nil
+}
+
+type testOrmRow struct {
+	Id   int64
+	Name string
+}
+
+func (r *testOrmRow) FieldMapping(m map[string]interface{}) {
+	m["id"] = &r.Id
+	m["name"] = &r.Name
+}
+
+func TestScanSqlRowsFunc(t *testing.T) {
+	scan := func(result testSqlResult, onRow func(row *testOrmRow) error) (names []string, err error) {
+		rows, err := openTestSql(t, result).Query("SELECT id, name FROM user")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer rows.Close()
+		err = ScanSqlRowsFunc(rows, []string{"name", "id"}, func() OrmFieldMapper { return new(testOrmRow) }, func(m OrmFieldMapper) error {
+			row := m.(*testOrmRow)
+			names = append(names, fmt.Sprintf("%d:%s", row.Id, row.Name))
+			return onRow(row)
+		})
+		return
+	}
+	result := testSqlResult{
+		columns: []string{"name", "id"},
+		rows:    [][]driver.Value{{"a", int64(1)}, {"b", int64(2)}, {"c", int64(3)}},
+	}
+	next := func(*testOrmRow) error { return nil }
+
+	// rows in order
+	if names, err := scan(result, next); err != nil || strings.Join(names, ",") != "1:a,2:b,3:c" {
+		t.Fatal(names, err)
+	}
+
+	// stop on onRow error
+	stop := errors.New("stop")
+	if names, err := scan(result, func(row *testOrmRow) error {
+		if row.Id == 2 {
+			return stop
+		}
+		return nil
+	}); err != stop || strings.Join(names, ",") != "1:a,2:b" {
+		t.Fatal(names, err)
+	}
+
+	// scan error
+	invalid := result
+	invalid.rows = [][]driver.Value{{"a", int64(1)}, {"b", "x"}, {"c", int64(3)}}
+	if names, err := scan(invalid, next); err == nil || strings.Join(names, ",") != "1:a" {
+		t.Fatal(names, err)
+	}
+
+	// rows error
+	broken := result
+	broken.err = errors.New("connection lost")
+	if names, err := scan(broken, next); err != broken.err || len(names) != 3 {
+		t.Fatal(names, err)
+	}
+}
+
+func TestScanRowsToMaps(t *testing.T) {
+	db := openTestSql(t, testSqlResult{
+		columns: []string{"id", "name", "raw", "data", "unknown"},
+		types: []reflect.Type{
+			reflect.TypeOf(int64(0)), reflect.TypeOf(""), reflect.TypeOf(sql.RawBytes{}), reflect.TypeOf([]byte{}), nil,
+		},
+		rows: [][]driver.Value{
+			{int64(1), "a", []byte("raw1"), []byte("data1"), 1.5},
+			{int64(2), "b", []byte("raw2"), []byte("data2"), nil},
+		},
+	})
+	rows, err := db.Query("SELECT * FROM user")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	ret, err := ScanRowsToMaps(rows)
+	if err != nil || len(ret) != 2 {
+		t.Fatal(ret, err)
+	}
+
+	// value types by ScanType or interface{}
+	var typs []string
+	for _, column := range []string{"id", "name", "raw", "data", "unknown"} {
+		typs = append(typs, fmt.Sprintf("%T", ret[0][column]))
+	}
+	if strings.Join(typs, ",") != "int64,string,[]uint8,[]uint8,float64" || ret[1]["unknown"] != nil {
+		t.Fatal(typs, ret)
+	}
+
+	// byte slices are copied from driver buffer reused by next row
+	if string(ret[0]["raw"].([]byte)) != "raw1" || string(ret[0]["data"].([]byte)) != "data1" ||
+		string(ret[1]["raw"].([]byte)) != "raw2" || string(ret[1]["data"].([]byte)) != "data2" {
+		t.Fatal(ret)
+	}
+}
+
+type testOrmSchemaLister struct{ testOrmDriver }
+
+func (testOrmSchemaLister) Schemas(dsn string) ([]string, error) { return []string{dsn + "/a"}, nil }
+
+func TestListOrmSchemas(t *testing.T) {
+	if schemas, err := ListOrmSchemas(testOrmDriver{}, "x"); err != nil || len(schemas) != 0 {
+		t.Fatal(schemas, err)
+	}
+	if schemas, err := ListOrmSchemas(testOrmSchemaLister{}, "x"); err != nil || strings.Join(schemas, ",") != "x/a" {
+		t.Fatal(schemas, err)
+	}
+}
+
+func TestQuerySqlSchemas(t *testing.T) {
+	result := testSqlResult{
+		columns: []string{"SCHEMA_NAME"},
+		rows:    [][]driver.Value{{"app"}, {[]byte("information_schema")}},
+	}
+	if schemas, err := QuerySqlSchemas(openTestSql(t, result)); err != nil || strings.Join(schemas, ",") != "app,information_schema" {
+		t.Fatal(schemas, err)
+	}
+
+	result.err = errors.New("connection lost")
+	if _, err := QuerySqlSchemas(openTestSql(t, result)); err != result.err {
+		t.Fatal(err)
+	}
+
+	result.err, result.rows = nil, [][]driver.Value{{nil}}
+	if _, err := QuerySqlSchemas(openTestSql(t, result)); err == nil {
+		t.Fatal("scan null into string")
+	}
+}
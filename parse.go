@@ -20,11 +20,18 @@ package zcore
 import (
 	"bytes"
 	"go/ast"
+	"go/parser"
 	"go/token"
 	"io/fs"
+	"io/ioutil"
+	"math"
 	"os"
 	"path/filepath"
+	"reflect"
+	"regexp"
+	"strconv"
 	"strings"
+	"sync"
 )
 
 var (
@@ -35,11 +42,136 @@ var (
 		"testdata":     {},
 	}
 
-	// declParsedStore to cached parsed AnnotatedDecls from *ast.File
-	// same *ast.File always has same parsed results
+	// declParsedStore to cached parsed AnnotatedDecls from *ast.File and prefix
+	// same *ast.File with same prefix always has same parsed results
 	declParsedStore = new(VersionStore)
+
+	// GeneratedSuffix is suffix of generated filename composed by DefaultOutputName
+	GeneratedSuffix = "_zz_generated"
+
+	// AnnotationTagKey is struct tag key to parse fields annotations from tag like `gozz:"validate:required"`.
+	// empty key disables tag annotations. parsed results are cached with key so it could be changed between parsing
+	AnnotationTagKey = ""
+
+	// OnFileParsed is optional progress callback invoked after each golang file parsed by
+	// ParseFileOrDirectory, ParseFileOrDirectoryStream and ParseFileOrDirectoryMulti.
+	// index starts from 1 and total is 0 if unknown
+	OnFileParsed func(path string, index, total int)
+
+	// ProgressCountFiles enables counting golang files in first pass of walking
+	// so total of OnFileParsed could be provided
+	ProgressCountFiles = false
+
+	// SkipGeneratedFiles skips files generated by gozz checked by IsGeneratedFile when walking directory
+	// in ParseFileOrDirectory, ParseFileOrDirectoryStream, ParseFileOrDirectoryMulti, ParseFS,
+	// ListCandidateFiles, CollectAnnotations and AnnotationStats.
+	// it prevents annotations copied into generated output from feeding back into next parsing
+	SkipGeneratedFiles = true
+
+	// DefaultValueTagKey is struct tag key of field default value read by AnnotatedField.DefaultValue like `default:"10"`
+	DefaultValueTagKey = "default"
 )
 
+var (
+	// annotationForms provides additional comment forms recognized as AnnotationPrefix like "gozz:"
+	annotationForms []string
+	// annotationFormsMu guards annotationForms for concurrent registrations
+	annotationFormsMu sync.RWMutex
+)
+
+// RegisterAnnotationForm registers additional annotation form written without space after "//"
+// as go directive convention. for example "gozz:" makes "//gozz:plugin:args" recognized as "// +zz:plugin:args".
+// forms only apply to AnnotationPrefix and parsed results are cached with registered forms
+func RegisterAnnotationForm(form string) {
+	annotationFormsMu.Lock()
+	defer annotationFormsMu.Unlock()
+	for _, f := range annotationForms {
+		if f == form {
+			return
+		}
+	}
+	annotationForms = append(annotationForms, form)
+}
+
+// normalizeAnnotationForm converts comment text matched registered annotation forms as AnnotationPrefix comment
+func normalizeAnnotationForm(text string) (ret string, ok bool) {
+	annotationFormsMu.RLock()
+	defer annotationFormsMu.RUnlock()
+	for _, form := range annotationForms {
+		if rest, exist := TrimPrefix(text, "//"+form); exist {
+			return "// " + AnnotationPrefix + rest, true
+		}
+	}
+	return text, false
+}
+
+// containsAnnotation checks data contains annotations prefix
+// or comments of registered annotation forms if prefix is AnnotationPrefix
+func containsAnnotation(data []byte, prefix string) bool {
+	if bytes.Contains(data, []byte(prefix)) {
+		return true
+	}
+	if prefix != AnnotationPrefix {
+		return false
+	}
+	annotationFormsMu.RLock()
+	defer annotationFormsMu.RUnlock()
+	for _, form := range annotationForms {
+		if bytes.Contains(data, []byte("//"+form)) {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeCommentGroup returns copied comment group with comments of registered annotation forms normalized.
+// directive-like comments would be dropped by ast.CommentGroup Text so they should be converted before
+func normalizeCommentGroup(g *ast.CommentGroup) *ast.CommentGroup {
+	var list []*ast.Comment
+	for i, c := range g.List {
+		text, ok := normalizeAnnotationForm(c.Text)
+		if !ok {
+			continue
+		}
+		if list == nil {
+			list = append([]*ast.Comment(nil), g.List...)
+		}
+		list[i] = &ast.Comment{Slash: c.Slash, Text: text}
+	}
+	if list == nil {
+		return g
+	}
+	return &ast.CommentGroup{List: list}
+}
+
+// AnnotationTagSeparator separates multiple annotations in struct tag value
+// like `gozz:"validate:required;json:name"`. literal ";" could be escaped as "\;"
+const AnnotationTagSeparator = ";"
+
+// ParseTagAnnotations parses annotations without prefix from struct tag value of key.
+// tag value is unquoted as reflect.StructTag so "\\:" in raw tag literal is "\:" in annotation
+func ParseTagAnnotations(tag *ast.BasicLit, key string) (annotations []string) {
+	if tag == nil || len(key) == 0 {
+		return
+	}
+	str, err := strconv.Unquote(tag.Value)
+	if err != nil {
+		return
+	}
+	value, ok := reflect.StructTag(str).Lookup(key)
+	if !ok {
+		return
+	}
+	const escaped = "\x00"
+	value = strings.ReplaceAll(value, `\`+AnnotationTagSeparator, escaped)
+	for _, annotation := range strings.Split(value, AnnotationTagSeparator) {
+		if annotation = strings.TrimSpace(strings.ReplaceAll(annotation, escaped, AnnotationTagSeparator)); len(annotation) > 0 {
+			annotations = append(annotations, annotation)
+		}
+	}
+	return
+}
+
 // Types of annotated declaration
 const (
 	DeclTypeInterface = iota + 1 // type T interface{}
@@ -52,6 +184,26 @@ const (
 	DeclValue                    // var variable = 1 or var v Type  or  const constant = 1
 )
 
+// declTypeNames provides human-readable names of annotated declaration types
+var declTypeNames = map[int]string{
+	DeclTypeInterface: "interface",
+	DeclTypeStruct:    "struct",
+	DeclTypeMap:       "map",
+	DeclTypeArray:     "array",
+	DeclTypeFunc:      "functype",
+	DeclTypeRefer:     "refer",
+	DeclFunc:          "func",
+	DeclValue:         "value",
+}
+
+// DeclTypeString return human-readable name of annotated declaration type or "unknown"
+func DeclTypeString(t int) string {
+	if name, ok := declTypeNames[t]; ok {
+		return name
+	}
+	return "unknown"
+}
+
 type (
 	AnnotatedDecl struct {
 		File *File
@@ -64,6 +216,13 @@ type (
 		Docs        []string
 		Annotations []string
 		Fields      []*AnnotatedField
+
+		// Directives contains directive comments of declaration docs such as "//go:noinline".
+		// directives are not included in Docs
+		Directives []string
+
+		// constType is type name of constant including implicit repetition in const block
+		constType string
 	}
 
 	AnnotatedField struct {
@@ -90,6 +249,67 @@ func (decl *AnnotatedDecl) Name() string {
 	return ""
 }
 
+// Pos return position of declaration node in file or token.NoPos if not found
+func (decl *AnnotatedDecl) Pos() token.Pos {
+	switch {
+	case decl.TypeSpec != nil:
+		return decl.TypeSpec.Pos()
+	case decl.FuncDecl != nil:
+		return decl.FuncDecl.Pos()
+	case decl.ValueSpec != nil:
+		return decl.ValueSpec.Pos()
+	}
+	return token.NoPos
+}
+
+// IsAlias checks decl is type alias declaration like "type T = T2"
+// rather than type definition like "type T T2" with its own method set
+func (decl *AnnotatedDecl) IsAlias() bool {
+	return decl.TypeSpec != nil && decl.TypeSpec.Assign.IsValid()
+}
+
+// FuncSignature extracts receiver, parameters and results of function declaration.
+// receiver is nil if function is not method. ok is false if decl is not function declaration
+func (decl *AnnotatedDecl) FuncSignature() (recv *Param, params, results []Param, ok bool) {
+	if decl.FuncDecl == nil || decl.FuncDecl.Type == nil {
+		return
+	}
+	if r := fieldListParams(decl.FuncDecl.Recv, decl.File); len(r) > 0 {
+		recv = &r[0]
+	}
+	params, results = FuncTypeInfo(decl.FuncDecl.Type, decl.File)
+	return recv, params, results, true
+}
+
+// Index returns declarations with name as key for fast lookup.
+// index is scoped per package, decls should be declared in same package such as grouped by IndexByDir.
+// if names are duplicated, first declaration would be kept. declarations without single name are skipped
+func (decls AnnotatedDecls) Index() map[string]*AnnotatedDecl {
+	m := make(map[string]*AnnotatedDecl, len(decls))
+	for _, decl := range decls {
+		if name := decl.Name(); len(name) > 0 {
+			if _, exist := m[name]; !exist {
+				m[name] = decl
+			}
+		}
+	}
+	return m
+}
+
+// IndexByDir returns declarations Index with declaration file directory as key
+func (decls AnnotatedDecls) IndexByDir() map[string]map[string]*AnnotatedDecl {
+	groups := make(map[string]AnnotatedDecls)
+	for _, decl := range decls {
+		dir := filepath.Dir(decl.File.Path)
+		groups[dir] = append(groups[dir], decl)
+	}
+	m := make(map[string]map[string]*AnnotatedDecl, len(groups))
+	for dir, group := range groups {
+		m[dir] = group.Index()
+	}
+	return m
+}
+
 // Filename return base filename from file ast
 func (decl *AnnotatedDecl) Filename() string { return filepath.Base(decl.File.Path) }
 
@@ -100,9 +320,22 @@ func (decl *AnnotatedDecl) Package() string { return decl.File.Ast.Name.Name }
 // if filename is absolute. filename would be related to mod file
 // else filename would be related to declaration file
 // if filename does not have ".go" suffix.
-// defaultName provided would be added as base name and origin filename as directory name
+// defaultName provided would be added as base name and origin filename as directory name.
+// templated filename escaping from related directory would be rejected as empty with warning.
+// see ResolveFilename to get error instead
 func (decl *AnnotatedDecl) RelFilename(filename string, defaultName string) (ret string) {
-	if strings.Contains(filename, "{{") && strings.Contains(filename, "}}") {
+	ret, err := decl.ResolveFilename(filename, defaultName)
+	if err != nil {
+		Warnf("%v", err)
+	}
+	return
+}
+
+// ResolveFilename resolves filename as RelFilename but returns error
+// if templated filename escapes from related directory
+func (decl *AnnotatedDecl) ResolveFilename(filename string, defaultName string) (string, error) {
+	templated := strings.Contains(filename, "{{") && strings.Contains(filename, "}}")
+	if templated {
 		TryExecuteTemplate(decl, filename, &filename)
 	}
 
@@ -111,28 +344,234 @@ func (decl *AnnotatedDecl) RelFilename(filename string, defaultName string) (ret
 		filename = filepath.Join(filename, defaultName)
 	}
 
-	if dir := filepath.Dir(decl.File.Path); filepath.IsAbs(filename) {
-		ret = filepath.Join(filepath.Dir(GetModFile(dir)), filename)
-	} else {
-		ret = filepath.Join(dir, filename)
+	base := filepath.Dir(decl.File.Path)
+	if filepath.IsAbs(filename) {
+		base, filename = filepath.Dir(GetModFile(base)), strings.TrimLeft(filename, `/\`)
 	}
-	return
+	if !templated {
+		return filepath.Join(base, filename), nil
+	}
+
+	// templated filename may be controlled by annotation and should not escape from base
+	return SafeJoin(base, filename)
+}
+
+// DefaultOutputName return generated filename composed with base and GeneratedSuffix like "types_zz_generated.go".
+// base could be template executed with decl. if base is empty, decl filename would be used as base
+func DefaultOutputName(decl *AnnotatedDecl, base string) string {
+	if strings.Contains(base, "{{") && strings.Contains(base, "}}") {
+		TryExecuteTemplate(decl, base, &base)
+	}
+	if len(base) == 0 {
+		base = decl.Filename()
+	}
+	base = strings.TrimSuffix(strings.TrimSuffix(base, ".go"), GeneratedSuffix)
+	return base + GeneratedSuffix + ".go"
 }
 
-// Parse parses declarations by plugin's name and args count. returns declaration entities with parsed args and options
+// Reserved options keys of cross plugins convention recognized with ParseConfig.SkipOptions
+const (
+	SkipOptionKey   = "skip"
+	IgnoreOptionKey = "ignore"
+)
+
+// ParseConfig provides extra controls of parsing annotated declarations into entities
+type ParseConfig struct {
+	// SkipOptions omits entities with reserved option "skip" or "ignore" which is truthy as Options.Exist
+	// for example "+zz:foo:skip" or "+zz:foo:ignore=true"
+	SkipOptions bool
+
+	// ExportedOnly omits unexported annotated declarations.
+	// annotated unexported fields of exported declarations would be omitted from entity Fields too
+	ExportedOnly bool
+
+	// OmitConstMembers omits constant entities whose type is annotated by plugin in same directory
+	// since they would be collected as members by DeclEntity.ConstMembers
+	OmitConstMembers bool
+}
+
+// DefaultParseConfig is used by AnnotatedDecls.Parse
+var DefaultParseConfig = ParseConfig{}
+
+// skip checks entity should be omitted by config
+func (config ParseConfig) skip(entity DeclEntity) bool {
+	return config.SkipOptions && (entity.Options.Exist(SkipOptionKey) || entity.Options.Exist(IgnoreOptionKey))
+}
+
+// AnnotationRewriter rewrites raw annotations of declaration before parsing
+// such as expanding macro annotation into several concrete annotations or normalizing annotations
+type AnnotationRewriter func(decl *AnnotatedDecl, annotations []string) []string
+
+var (
+	// annotationRewriters provides registered AnnotationRewriter chain applied in registered order
+	annotationRewriters []AnnotationRewriter
+	// annotationRewritersMu guards annotationRewriters for concurrent registrations
+	annotationRewritersMu sync.RWMutex
+)
+
+// RegisterAnnotationRewriter appends AnnotationRewriter into rewriters chain
+func RegisterAnnotationRewriter(rewriter AnnotationRewriter) {
+	annotationRewritersMu.Lock()
+	annotationRewriters = append(annotationRewriters, rewriter)
+	annotationRewritersMu.Unlock()
+}
+
+// rewriteAnnotations returns copied declaration with annotations rewritten by registered rewriters chain.
+// declaration would be returned as is if no rewriter registered
+func (decl *AnnotatedDecl) rewriteAnnotations(rewriters []AnnotationRewriter) *AnnotatedDecl {
+	if len(rewriters) == 0 {
+		return decl
+	}
+	d := *decl
+	d.Annotations = append([]string(nil), decl.Annotations...)
+	for _, rewriter := range rewriters {
+		d.Annotations = rewriter(&d, d.Annotations)
+	}
+	return &d
+}
+
+// Parse parses declarations with DefaultParseConfig. see ParseWithConfig
 func (decls AnnotatedDecls) Parse(plugin Plugin, extOptions map[string]string) (entities DeclEntities) {
+	return decls.ParseWithConfig(plugin, extOptions, DefaultParseConfig)
+}
+
+// ParseWithConfig parses declarations by plugin's name and args count. returns declaration entities with parsed args and options
+// if plugin implements PluginArgsArity, args count range would be used instead of args count
+// if plugin implements PluginOptionDefaults, defaults would be applied to options not provided
+// annotations would be rewritten by registered AnnotationRewriter chain before parsing
+func (decls AnnotatedDecls) ParseWithConfig(plugin Plugin, extOptions map[string]string, config ParseConfig) (entities DeclEntities) {
+	// unwrap plugin entity to check optional interfaces of underlying plugin
+	if entity, ok := plugin.(PluginEntity); ok {
+		plugin = entity.Plugin
+	}
+
 	name := plugin.Name()
 	args, _ := plugin.Args()
+
+	if p, ok := plugin.(PluginOptionDefaults); ok {
+		extOptions = Options(p.OptionDefaults()).Merge(extOptions, true)
+	}
+
+	minArgs, maxArgs := len(args), len(args)
+	if p, ok := plugin.(PluginArgsArity); ok {
+		minArgs, maxArgs = p.ArgsArity()
+	}
+
+	aliases := PluginAliases(name)
+
+	annotationRewritersMu.RLock()
+	rewriters := annotationRewriters
+	annotationRewritersMu.RUnlock()
+
 	for _, decl := range decls {
-		entities = append(entities, decl.parse(name, len(args), extOptions)...)
+		if config.ExportedOnly {
+			if !decl.IsExported() {
+				continue
+			}
+			decl = decl.exportedFields()
+		}
+		decl = decl.rewriteAnnotations(rewriters)
+		for _, entity := range decl.parseArity(name, minArgs, maxArgs, extOptions, aliases...) {
+			if !config.skip(entity) {
+				entities = append(entities, entity)
+			}
+		}
+	}
+	if config.OmitConstMembers {
+		entities = entities.omitConstMembers()
 	}
 	return
 }
 
+// omitConstMembers omits constant entities whose type is annotated type entity in same directory
+// since they would be collected as members by DeclEntity.ConstMembers
+func (entities DeclEntities) omitConstMembers() DeclEntities {
+	types := make(map[string]bool)
+	for _, entity := range entities {
+		if entity.TypeSpec != nil && entity.File != nil {
+			types[filepath.Dir(entity.File.Path)+"#"+entity.Name()] = true
+		}
+	}
+	if len(types) == 0 {
+		return entities
+	}
+
+	ret := entities[:0:0]
+	for _, entity := range entities {
+		if len(entity.constType) > 0 && entity.File != nil && types[filepath.Dir(entity.File.Path)+"#"+entity.constType] {
+			continue
+		}
+		ret = append(ret, entity)
+	}
+	return ret
+}
+
+// IsExported checks declaration name is exported.
+// value declaration with multiple names is exported if any name is exported
+func (decl *AnnotatedDecl) IsExported() bool {
+	if decl.ValueSpec != nil && len(decl.ValueSpec.Names) > 1 {
+		for _, name := range decl.ValueSpec.Names {
+			if name.IsExported() {
+				return true
+			}
+		}
+		return false
+	}
+	return token.IsExported(decl.Name())
+}
+
+// IsExported checks field name or embedded type name is exported.
+// field declared with multiple names is exported if any name is exported
+func (field *AnnotatedField) IsExported() bool {
+	if field.Field == nil {
+		return false
+	}
+	if len(field.Field.Names) == 0 {
+		name := ExtractAnonymousName(field.Field.Type)
+		return name != nil && name.IsExported()
+	}
+	for _, name := range field.Field.Names {
+		if name.IsExported() {
+			return true
+		}
+	}
+	return false
+}
+
+// exportedFields returns copied declaration without unexported annotated fields
+func (decl *AnnotatedDecl) exportedFields() *AnnotatedDecl {
+	for i, field := range decl.Fields {
+		if field.IsExported() {
+			continue
+		}
+		fields := append([]*AnnotatedField(nil), decl.Fields[:i]...)
+		for _, field := range decl.Fields[i+1:] {
+			if field.IsExported() {
+				fields = append(fields, field)
+			}
+		}
+		d := *decl
+		d.Fields = fields
+		return &d
+	}
+	return decl
+}
+
 // parse analysis annotated declarations annotations matched with name and args count. and convert into args and options.
 func (decl *AnnotatedDecl) parse(name string, argsCount int, extOptions map[string]string) (entities DeclEntities) {
+	return decl.parseArity(name, argsCount, argsCount, extOptions)
+}
+
+// parseArity parses annotated declarations annotations as parse with args count range.
+// annotations matched with deprecated aliases would be parsed as name
+func (decl *AnnotatedDecl) parseArity(name string, minArgs, maxArgs int, extOptions map[string]string, aliases ...string) (entities DeclEntities) {
 	for _, annotation := range decl.Annotations {
-		args, opts, ok := parseAnnotation(annotation, name, argsCount, extOptions)
+		args, opts, ok := parseAnnotationArity(annotation, name, minArgs, maxArgs, extOptions)
+		for i := 0; !ok && i < len(aliases); i++ {
+			if args, opts, ok = parseAnnotationArity(annotation, aliases[i], minArgs, maxArgs, extOptions); ok && WarnDeprecatedAlias {
+				Logger.Printf("warning: annotation %q uses deprecated name %q of plugin %q\n", annotation, aliases[i], name)
+			}
+		}
 		if !ok {
 			continue
 		}
@@ -141,15 +580,115 @@ func (decl *AnnotatedDecl) parse(name string, argsCount int, extOptions map[stri
 			Plugin:        name,
 			Args:          args,
 			Options:       opts,
+			Raw:           annotation,
+			Priority:      annotationPriority(annotation),
 		})
 	}
 	return
 }
 
+// TypeString return field type source text such as "map[string][]*pkg.T".
+// interface method type would be returned as func signature like "func(ctx context.Context) error"
+func (field *AnnotatedField) TypeString() string {
+	if field.Field == nil || field.Field.Type == nil {
+		return ""
+	}
+	typ := string(field.Decl.File.Node(field.Field.Type))
+	if ft, ok := field.Field.Type.(*ast.FuncType); ok && !ft.Func.IsValid() {
+		// interface method has no func keyword
+		typ = "func" + typ
+	}
+	return typ
+}
+
+// TypePackagePath extracts leftmost package selector from field type such as "bar" of "[]*bar.Baz"
+// and resolves its import path by file imports. ok is false if field type has no package selector
+func (field *AnnotatedField) TypePackagePath() (path string, ok bool) {
+	if field.Field == nil || field.Field.Type == nil {
+		return
+	}
+	var pkg string
+	ast.Inspect(field.Field.Type, func(node ast.Node) bool {
+		if len(pkg) > 0 {
+			return false
+		}
+		if sel, is := node.(*ast.SelectorExpr); is {
+			if ident, is := sel.X.(*ast.Ident); is {
+				pkg = ident.Name
+				return false
+			}
+		}
+		return true
+	})
+	if len(pkg) == 0 {
+		return
+	}
+	path = field.Decl.File.Imports().Which(pkg)
+	return path, len(path) > 0
+}
+
+// DefaultValue reads field struct tag of DefaultValueTagKey and converts value into golang literal of field type.
+// string value would be quoted and bool or numeric value would be validated and left bare.
+// non-finite float value such as NaN or Inf is rejected as it has no golang literal.
+// ok is false if tag is empty or value could not be converted into field type
+func (field *AnnotatedField) DefaultValue() (expr string, ok bool) {
+	if field.Field == nil || field.Field.Tag == nil || len(DefaultValueTagKey) == 0 {
+		return
+	}
+	tag, err := strconv.Unquote(field.Field.Tag.Value)
+	if err != nil {
+		return
+	}
+	value, exist := reflect.StructTag(tag).Lookup(DefaultValueTagKey)
+	if !exist || len(value) == 0 {
+		return
+	}
+	ident, is := field.Field.Type.(*ast.Ident)
+	if !is {
+		return
+	}
+
+	bits := numericBits[ident.Name]
+	switch typ := ident.Name; typ {
+	case "string":
+		return strconv.Quote(value), true
+	case "bool":
+		if b, e := strconv.ParseBool(value); e == nil {
+			return strconv.FormatBool(b), true
+		}
+	case "int", "int8", "int16", "int32", "int64", "rune":
+		if _, e := strconv.ParseInt(value, 0, bits); e == nil {
+			return value, true
+		}
+	case "uint", "uint8", "uint16", "uint32", "uint64", "uintptr", "byte":
+		if _, e := strconv.ParseUint(value, 0, bits); e == nil {
+			return value, true
+		}
+	case "float32", "float64":
+		// NaN and Inf are accepted by ParseFloat but have no literal in golang
+		if f, e := strconv.ParseFloat(value, bits); e == nil && !math.IsNaN(f) && !math.IsInf(f, 0) {
+			return value, true
+		}
+	}
+	return
+}
+
+// numericBits provides bit size of builtin numeric types to validate default value in range
+var numericBits = map[string]int{
+	"int": strconv.IntSize, "int8": 8, "int16": 16, "int32": 32, "int64": 64, "rune": 32,
+	"uint": strconv.IntSize, "uint8": 8, "uint16": 16, "uint32": 32, "uint64": 64, "uintptr": strconv.IntSize, "byte": 8,
+	"float32": 32, "float64": 64,
+}
+
 // Parse analysis annotated fields annotations matched with name and args count. and convert into args and options.
+// annotations matched with deprecated aliases registered by RegisterPluginAlias would be parsed as name
 func (field *AnnotatedField) Parse(name string, argsCount int, extOptions map[string]string) (entities FieldEntities) {
+	aliases := PluginAliases(name)
 	for _, annotation := range field.Annotations {
 		args, opts, ok := parseAnnotation(annotation, name, argsCount, extOptions)
+		for i := 0; !ok && i < len(aliases); i++ {
+			args, opts, ok = parseAnnotation(annotation, aliases[i], argsCount, extOptions)
+		}
 		if !ok {
 			continue
 		}
@@ -157,6 +696,7 @@ func (field *AnnotatedField) Parse(name string, argsCount int, extOptions map[st
 			AnnotatedField: field,
 			Args:           args,
 			Options:        opts,
+			Raw:            annotation,
 		})
 	}
 	return
@@ -165,23 +705,203 @@ func (field *AnnotatedField) Parse(name string, argsCount int, extOptions map[st
 // ParseFileOrDirectory try parse provided path annotated declarations with annotations prefix
 // if directory provided. walks file tree from provided path as root and returns all parsed
 func ParseFileOrDirectory(path string, prefix string) (decls AnnotatedDecls, err error) {
+	err = parseFileOrDirectoryStream(path, prefix, parseFileDeclsCached, func(_ *File, fileDecls AnnotatedDecls) error {
+		decls = append(decls, fileDecls...)
+		return nil
+	})
+	return
+}
+
+// ParseFileOrDirectoryStream try parse provided path annotated declarations with annotations prefix
+// and invoke fn with each file and its annotated declarations as parsing completes.
+// files without annotated declarations would not be passed to fn.
+// if directory provided. walks file tree from provided path as root.
+// file data, ast and parsed declarations would not be cached as ParseFileDecls
+// so memory could be released once fn returns and caller drops references
+func ParseFileOrDirectoryStream(path string, prefix string, fn func(file *File, decls AnnotatedDecls) error) (err error) {
+	return parseFileOrDirectoryStream(path, prefix, parseFileDeclsUncached, fn)
+}
+
+// parseFileOrDirectoryStream walks path and parses each file with parseFile then invokes fn with file declarations
+func parseFileOrDirectoryStream(path, prefix string, parseFile func(filename, prefix string, walking bool) (AnnotatedDecls, error),
+	fn func(file *File, decls AnnotatedDecls) error) (err error) {
 	stat, err := os.Stat(path)
 	if err != nil {
 		return
 	}
 
+	parse := withProgress(path, stat.IsDir(), func(filename string) error {
+		decls, err := parseFile(filename, prefix, stat.IsDir())
+		if err != nil || len(decls) == 0 {
+			return err
+		}
+		return fn(decls[0].File, decls)
+	})
+
 	if !stat.IsDir() {
 		// single file
-		return ParseFileDecls(path, prefix)
+		return parse(path)
 	}
 
 	// directory
 	// walk all child directories and files
+	return WalkTree(path, parse)
+}
+
+// parseFileDeclsCached parses file with ParseFileDecls and skips generated file in directory walking
+func parseFileDeclsCached(filename, prefix string, walking bool) (AnnotatedDecls, error) {
+	if walking && skipGenerated(filename) {
+		return nil, nil
+	}
+	return ParseFileDecls(filename, prefix)
+}
+
+// parseFileDeclsUncached parses file as ParseFileDecls without caching file data, ast and results.
+// generated file would be skipped in directory walking
+func parseFileDeclsUncached(filename, prefix string, walking bool) (decls AnnotatedDecls, err error) {
+	if filename, err = filepath.Abs(filename); err != nil || !IsGoFile(filename) {
+		return
+	}
+	data, err := ioutil.ReadFile(filename)
+	if err != nil || !containsAnnotation(data, prefix) {
+		return
+	}
+	if walking && skipGeneratedData(data) {
+		return
+	}
+	return ParseSource(filename, data, prefix)
+}
+
+// skipGenerated checks golang file should be skipped in directory walking as generated file
+func skipGenerated(filename string) bool {
+	return SkipGeneratedFiles && IsGoFile(filename) && IsGeneratedFile(filename)
+}
+
+// skipGeneratedData checks file data should be skipped in directory walking as generated file
+func skipGeneratedData(data []byte) bool {
+	if !SkipGeneratedFiles {
+		return false
+	}
+	_, generated := GeneratedPlugin(data)
+	return generated
+}
+
+// withProgress wraps parse function of golang files to invoke OnFileParsed after each file parsed
+func withProgress(path string, isDir bool, parse func(filename string) error) func(filename string) error {
+	callback := OnFileParsed
+	if callback == nil {
+		return parse
+	}
+
+	index, total := 0, 0
+	if !isDir {
+		total = 1
+	} else if ProgressCountFiles {
+		_ = WalkTree(path, func(filename string) error {
+			if IsGoFile(filename) {
+				total++
+			}
+			return nil
+		})
+	}
 
-	// use error group and pre alloc slots to collect parsed results
-	slots := make([]*AnnotatedDecls, 0)
+	return func(filename string) error {
+		if err := parse(filename); err != nil || !IsGoFile(filename) {
+			return err
+		}
+		index++
+		callback(filename, index, total)
+		return nil
+	}
+}
 
-	if err = filepath.Walk(path, func(filename string, info fs.FileInfo, e error) (err error) {
+// ListCandidateFiles walks provided file or directory as ParseFileOrDirectory
+// and returns absolute filenames of golang files containing annotations prefix without parsing ast.
+// generated files are skipped in directory walking as SkipGeneratedFiles
+func ListCandidateFiles(path string, prefix string) (filenames []string, err error) {
+	stat, err := os.Stat(path)
+	if err != nil {
+		return
+	}
+
+	check := func(filename string) error {
+		filename, err := filepath.Abs(filename)
+		if err != nil || !IsGoFile(filename) {
+			return err
+		}
+		data, _, err := ReadFile(filename)
+		if err != nil {
+			return err
+		}
+		if stat.IsDir() && skipGeneratedData(data) {
+			return nil
+		}
+		if containsAnnotation(data, prefix) {
+			filenames = append(filenames, filename)
+		}
+		return nil
+	}
+
+	if !stat.IsDir() {
+		err = check(path)
+	} else {
+		err = WalkTree(path, check)
+	}
+	return
+}
+
+// declParsedKey is key of declParsedStore.
+// parsed results also depend on AnnotationTagKey and registered annotation forms
+type declParsedKey struct {
+	file   *ast.File
+	prefix string
+	tagKey string
+	forms  string
+}
+
+// newDeclParsedKey returns cache key of parsed annotated declarations with current parsing settings
+func newDeclParsedKey(file *ast.File, prefix string) declParsedKey {
+	annotationFormsMu.RLock()
+	forms := strings.Join(annotationForms, "\n")
+	annotationFormsMu.RUnlock()
+	return declParsedKey{file: file, prefix: prefix, tagKey: AnnotationTagKey, forms: forms}
+}
+
+// ParseFileOrDirectoryMulti parses provided path annotated declarations with multiple annotations prefixes
+// in single file tree walk. returns declarations with prefix as key
+func ParseFileOrDirectoryMulti(path string, prefixes []string) (decls map[string]AnnotatedDecls, err error) {
+	stat, err := os.Stat(path)
+	if err != nil {
+		return
+	}
+
+	decls = make(map[string]AnnotatedDecls, len(prefixes))
+	parse := withProgress(path, stat.IsDir(), func(filename string) error {
+		if stat.IsDir() && skipGenerated(filename) {
+			return nil
+		}
+		for _, prefix := range prefixes {
+			fileDecls, err := ParseFileDecls(filename, prefix)
+			if err != nil {
+				return err
+			}
+			decls[prefix] = append(decls[prefix], fileDecls...)
+		}
+		return nil
+	})
+
+	if !stat.IsDir() {
+		err = parse(path)
+	} else {
+		err = WalkTree(path, parse)
+	}
+	return
+}
+
+// WalkTree walks file tree from provided path as root and invoke fn with each filename.
+// directories in SkipDirs or starts with "." would be skipped
+func WalkTree(path string, fn func(filename string) error) error {
+	return filepath.Walk(path, func(filename string, info fs.FileInfo, e error) (err error) {
 		if e != nil {
 			return e
 		}
@@ -194,20 +914,41 @@ func ParseFileOrDirectory(path string, prefix string) (decls AnnotatedDecls, err
 			return
 		}
 
-		// parse files with goroutine error group
-		// results would be placed in slot
-		index := len(slots)
-		slots = append(slots, new(AnnotatedDecls))
-		*slots[index], err = ParseFileDecls(filename, prefix)
-		return
-	}); err != nil {
-		return
-	}
+		return fn(filename)
+	})
+}
 
-	// expand results from slots
-	for _, slot := range slots {
-		decls = append(decls, *slot...)
-	}
+// ParseFS parses annotated declarations of golang files under root of fsys such as embed.FS or virtual filesystem.
+// directories are skipped as WalkTree and generated files are skipped as SkipGeneratedFiles.
+// File path of declarations is slash separated name in fsys.
+// files are not cached and module resolution such as File.Imports may degrade since paths may not be on disk
+func ParseFS(fsys fs.FS, root, prefix string) (decls AnnotatedDecls, err error) {
+	err = fs.WalkDir(fsys, root, func(name string, d fs.DirEntry, e error) error {
+		if e != nil {
+			return e
+		}
+
+		if d.IsDir() {
+			if _, skip := SkipDirs[d.Name()]; name != root && (skip || strings.HasPrefix(d.Name(), ".")) {
+				return fs.SkipDir
+			}
+			return nil
+		}
+
+		if !IsGoFile(name) {
+			return nil
+		}
+		data, err := ReadFileFS(fsys, name)
+		if err != nil || !containsAnnotation(data, prefix) || skipGeneratedData(data) {
+			return err
+		}
+		fileDecls, err := ParseSource(name, data, prefix)
+		if err != nil {
+			return err
+		}
+		decls = append(decls, fileDecls...)
+		return nil
+	})
 	return
 }
 
@@ -230,18 +971,18 @@ func ParseFileDecls(filename string, prefix string) (decls AnnotatedDecls, err e
 	}
 
 	// check data contains annotations prefix or return
-	if !bytes.Contains(data, []byte(prefix)) {
+	if !containsAnnotation(data, prefix) {
 		return
 	}
 
 	// parse file ast
-	f, err := ParseFile(filename)
+	f, err := GetFile(filename)
 	if err != nil {
 		return
 	}
 
 	// parse annotated decls
-	ret, _ := declParsedStore.Load(f.Ast, version, func() (interface{}, error) {
+	ret, _ := declParsedStore.Load(newDeclParsedKey(f.Ast, prefix), version, func() (interface{}, error) {
 		return parseFileDecls(f, prefix), nil
 	})
 
@@ -249,6 +990,17 @@ func ParseFileDecls(filename string, prefix string) (decls AnnotatedDecls, err e
 	return
 }
 
+// ParseSource parses golang source data in memory and analysis declarations annotations.
+// filename is used as File path only and would not be read. results would not be cached
+func ParseSource(filename string, src []byte, prefix string) (decls AnnotatedDecls, err error) {
+	src = bytes.TrimPrefix(src, utf8BOM)
+	f, err := parser.ParseFile(token.NewFileSet(), filename, src, parser.ParseComments)
+	if err != nil {
+		return
+	}
+	return parseFileDecls(&File{Path: filename, Data: src, Ast: f}, prefix), nil
+}
+
 func parseFileDecls(file *File, prefix string) (decls AnnotatedDecls) {
 	for _, astDecl := range file.Ast.Decls {
 		for _, decl := range ParseDecls(astDecl, prefix) {
@@ -259,9 +1011,54 @@ func parseFileDecls(file *File, prefix string) (decls AnnotatedDecls) {
 	return
 }
 
+// OnlyOptionKey is reserved option key of group annotation restricts propagation to named members
+// of merged variable or constant declaration. for example "+zz:foo:only=V1,V2"
+const OnlyOptionKey = "only"
+
+// targetAnnotations returns group annotations targeting provided value spec
+// annotations without option "only" target all members
+func targetAnnotations(annotations []string, vs *ast.ValueSpec) (ret []string) {
+	for _, annotation := range annotations {
+		if targets, ok := annotationTargets(annotation); !ok || matchTargets(targets, vs.Names) {
+			ret = append(ret, annotation)
+		}
+	}
+	return
+}
+
+// annotationTargets parses member names of option "only" from annotation
+func annotationTargets(annotation string) (targets []string, ok bool) {
+	sp := splitAnnotation(EscapeAnnotation(annotation))
+	for _, elem := range sp[1:] {
+		if key, value := SplitKV(elem, KeyValueSeparator); key == OnlyOptionKey && strings.Contains(elem, KeyValueSeparator) {
+			targets, ok = append(targets, strings.Split(UnescapeAnnotation(value), ",")...), true
+		}
+	}
+	return
+}
+
+// matchTargets checks any of names in targets
+func matchTargets(targets []string, names []*ast.Ident) bool {
+	for _, target := range targets {
+		for _, name := range names {
+			if strings.TrimSpace(target) == name.Name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 // ParseGenericDecl parse generic declaration to match annotation prefix
 func ParseGenericDecl(gen *ast.GenDecl, prefix string) (decls AnnotatedDecls) {
+	// import declaration would never be annotated.
+	// skip cgo preamble comments of import "C" which may contains any text like annotations
+	if gen.Tok == token.IMPORT {
+		return
+	}
+
 	genDocs, genAnnotations := ParseCommentGroup(prefix, gen.Doc)
+	genDirectives := ParseDirectives(gen.Doc)
 
 	single := !gen.Lparen.IsValid() || len(gen.Specs) == 1
 
@@ -288,29 +1085,43 @@ func ParseGenericDecl(gen *ast.GenDecl, prefix string) (decls AnnotatedDecls) {
 			// +zz:annotation:args:key=value
 			const constantC = 4
 		*/
+		var typ ast.Expr
 		for _, spec := range gen.Specs {
 			vs, ok := spec.(*ast.ValueSpec)
 			if !ok {
 				continue
 			}
 
+			// implicit repetition of previous type and values in const block
+			if gen.Tok == token.CONST && (vs.Type != nil || len(vs.Values) > 0) {
+				typ = vs.Type
+			}
+
 			docs, annotations := ParseCommentGroup(prefix, vs.Doc, vs.Comment)
 			// generic annotations would be appended to each element in merged declaration
+			// unless restricted to specified members by option "only"
 
-			if annotations = append(genAnnotations, annotations...); len(annotations) == 0 {
+			if annotations = append(targetAnnotations(genAnnotations, vs), annotations...); len(annotations) == 0 {
 				continue
 			}
 
+			directives := ParseDirectives(vs.Doc)
 			if single {
 				docs = append(genDocs, docs...)
+				directives = append(genDirectives, directives...)
 			}
 
-			decls = append(decls, &AnnotatedDecl{
+			decl := &AnnotatedDecl{
 				ValueSpec:   vs,
 				Docs:        docs,
 				Annotations: annotations,
+				Directives:  directives,
 				Type:        DeclValue,
-			})
+			}
+			if ident, ok := typ.(*ast.Ident); ok && gen.Tok == token.CONST {
+				decl.constType = ident.Name
+			}
+			decls = append(decls, decl)
 		}
 
 	case token.TYPE:
@@ -368,14 +1179,17 @@ func ParseGenericDecl(gen *ast.GenDecl, prefix string) (decls AnnotatedDecls) {
 				continue
 			}
 
+			directives := ParseDirectives(spec.Doc)
 			if single {
 				docs = append(genDocs, docs...)
+				directives = append(genDirectives, directives...)
 			}
 
 			decl := &AnnotatedDecl{
 				TypeSpec:    spec,
 				Docs:        docs,
 				Annotations: annotations,
+				Directives:  directives,
 			}
 
 			// check type spec type
@@ -405,13 +1219,15 @@ func ParseGenericDecl(gen *ast.GenDecl, prefix string) (decls AnnotatedDecls) {
 }
 
 // parseAnnotatedFields parse fields docs and comments to match annotations prefix
-// fields match annotations will be collect as AnnotatedField
+// fields match annotations will be collect as AnnotatedField.
+// if AnnotationTagKey is set, annotations in field tag would be appended after comments annotations
 func (decl *AnnotatedDecl) parseAnnotatedFields(fl *ast.FieldList, prefix string) {
 	for _, field := range fl.List {
 		if len(field.Names) == 0 {
 			continue
 		}
-		if docs, annotations := ParseCommentGroup(prefix, field.Doc, field.Comment); len(annotations) > 0 {
+		docs, annotations := ParseCommentGroup(prefix, field.Doc, field.Comment)
+		if annotations = append(annotations, ParseTagAnnotations(field.Tag, AnnotationTagKey)...); len(annotations) > 0 {
 			decl.Fields = append(decl.Fields, &AnnotatedField{
 				Docs:        docs,
 				Annotations: annotations,
@@ -438,6 +1254,7 @@ func ParseFuncDecl(decl *ast.FuncDecl, prefix string) (d *AnnotatedDecl) {
 		FuncDecl:    decl,
 		Docs:        docs,
 		Annotations: annotations,
+		Directives:  ParseDirectives(decl.Doc),
 		Type:        DeclFunc,
 	}
 }
@@ -457,6 +1274,7 @@ func ParseDecls(d ast.Decl, prefix string) (items AnnotatedDecls) {
 }
 
 // ParseCommentGroup extract comment group text and split by lines
+// line comments and each line in block comments are handled in same way.
 // if line match annotation prefix then append line to annotations
 // else append line to docs
 func ParseCommentGroup(prefix string, cg ...*ast.CommentGroup) (docs, annotations []string) {
@@ -464,25 +1282,216 @@ func ParseCommentGroup(prefix string, cg ...*ast.CommentGroup) (docs, annotation
 		if g == nil {
 			continue
 		}
-		docs = append(docs, strings.Split(strings.TrimSpace(g.Text()), "\n")...)
+		if prefix == AnnotationPrefix {
+			g = normalizeCommentGroup(g)
+		}
+		docs = append(docs, dedentLines(g.Text(), prefix)...)
 	}
 
+	return SplitAnnotations(prefix, docs)
+}
+
+// directiveRegexp matches directive comments like "//go:noinline" or "//lint:ignore" as go/ast
+var directiveRegexp = regexp.MustCompile(`^//(line |extern |export |[a-z0-9]+:[a-z0-9])`)
+
+// ParseDirectives returns directive comments like "//go:noinline" from comment groups in order.
+// directives are omitted from ast.CommentGroup Text so they never appear in docs.
+// comments of registered annotation forms are not directives
+func ParseDirectives(cg ...*ast.CommentGroup) (directives []string) {
+	for _, g := range cg {
+		if g == nil {
+			continue
+		}
+		for _, c := range g.List {
+			if _, ok := normalizeAnnotationForm(c.Text); !ok && directiveRegexp.MatchString(c.Text) {
+				directives = append(directives, c.Text)
+			}
+		}
+	}
+	return
+}
+
+// dedentLines splits comment text into lines without surrounding blank lines
+// and removes common leading indentation of doc lines such as indented block comment.
+// relative indentation of lines would be kept. annotation lines matched prefix are not counted
+func dedentLines(text string, prefix string) []string {
+	lines := strings.Split(strings.Trim(text, "\n"), "\n")
+
+	indent := -1
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if len(trimmed) == 0 || len(prefix) > 0 && strings.HasPrefix(trimmed, prefix) {
+			continue
+		}
+		if n := len(line) - len(strings.TrimLeft(line, " \t")); indent < 0 || n < indent {
+			indent = n
+		}
+	}
+
+	// remove at most indent leading whitespaces
+	for i, line := range lines {
+		n := 0
+		for n < indent && n < len(line) && (line[n] == ' ' || line[n] == '\t') {
+			n++
+		}
+		lines[i] = line[n:]
+	}
+	return lines
+}
+
+// SplitAnnotations split comment lines without comment markers into docs and annotations.
+// if line match annotation prefix then append line without prefix to annotations
+// else append line to docs. lines slice memory would be reused by docs
+func SplitAnnotations(prefix string, lines []string) (docs, annotations []string) {
 	// no prefix provided. return all comment lines as doc
 	if len(prefix) == 0 {
-		return docs, nil
+		return lines, nil
 	}
 
 	// comments matched annotation prefix would be appended as annotations
 	// or appended as docs in same slice memory
 	offset := 0
-	for _, doc := range docs {
+	for _, doc := range lines {
 		if annotation, exist := TrimPrefix(strings.TrimSpace(doc), prefix); exist {
 			annotations = append(annotations, annotation)
 		} else {
-			docs[offset] = doc
+			lines[offset] = doc
 			offset++
 		}
 	}
-	docs = docs[:offset]
+	docs = lines[:offset]
+	return
+}
+
+// AnnotationRef represents an annotation found in golang file comments
+type AnnotationRef struct {
+	Filename string
+	Line     int
+	Column   int
+	Name     string // plugin name of annotation
+	Raw      string // annotation text without AnnotationPrefix
+}
+
+// CollectAnnotations walks golang files from provided file or directory
+// and collects all comment lines starting with AnnotationPrefix.
+// files without annotations are filtered by scanning data without parsing ast.
+// generated files are skipped in directory walking as SkipGeneratedFiles.
+// plugins are not required to be registered so annotations names could be validated by tools
+func CollectAnnotations(path string) (refs []AnnotationRef, err error) {
+	stat, err := os.Stat(path)
+	if err != nil {
+		return
+	}
+
+	collect := func(filename string) error {
+		if !IsGoFile(filename) {
+			return nil
+		}
+		data, _, err := ReadFile(filename)
+		if err != nil || !containsAnnotation(data, AnnotationPrefix) || stat.IsDir() && skipGeneratedData(data) {
+			return err
+		}
+		file, err := ParseFile(filename)
+		if err != nil {
+			return err
+		}
+		refs = append(refs, collectFileAnnotations(file, AnnotationPrefix)...)
+		return nil
+	}
+
+	if !stat.IsDir() {
+		err = collect(path)
+	} else {
+		err = WalkTree(path, collect)
+	}
 	return
 }
+
+// AnnotationStats walks golang files from provided file or directory as CollectAnnotations
+// and counts annotations matched any of prefixes with plugin name as key.
+// plugins are not required to be registered and counts of same name from different prefixes would be summed
+func AnnotationStats(path string, prefixes []string) (stats map[string]int, err error) {
+	stat, err := os.Stat(path)
+	if err != nil {
+		return
+	}
+
+	stats = make(map[string]int)
+	count := func(filename string) error {
+		if !IsGoFile(filename) {
+			return nil
+		}
+		data, _, err := ReadFile(filename)
+		if err != nil || stat.IsDir() && skipGeneratedData(data) {
+			return err
+		}
+		// scan data before parsing ast
+		contains := false
+		for i := 0; !contains && i < len(prefixes); i++ {
+			contains = len(prefixes[i]) > 0 && containsAnnotation(data, prefixes[i])
+		}
+		if !contains {
+			return nil
+		}
+		file, err := ParseFile(filename)
+		if err != nil {
+			return err
+		}
+		for _, prefix := range prefixes {
+			if len(prefix) == 0 {
+				continue
+			}
+			for _, ref := range collectFileAnnotations(file, prefix) {
+				stats[ref.Name]++
+			}
+		}
+		return nil
+	}
+
+	if !stat.IsDir() {
+		err = count(path)
+	} else {
+		err = WalkTree(path, count)
+	}
+	return
+}
+
+// collectFileAnnotations collects annotations matched prefix in file comments with line and column
+func collectFileAnnotations(file *File, prefix string) (refs []AnnotationRef) {
+	for _, group := range file.Ast.Comments {
+		for _, comment := range group.List {
+			offset := int(comment.Pos() - 1)
+			text, normalized := comment.Text, false
+			if prefix == AnnotationPrefix {
+				text, normalized = normalizeAnnotationForm(text)
+			}
+			for _, line := range strings.Split(text, "\n") {
+				if index := strings.Index(line, prefix); index >= 0 && isCommentMarker(line[:index]) {
+					raw := strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(line[index+len(prefix):]), "*/"))
+					pos := offset + index
+					if normalized {
+						pos = offset
+					}
+					refs = append(refs, AnnotationRef{
+						Filename: file.Path,
+						Line:     bytes.Count(file.Data[:pos], []byte("\n")) + 1,
+						Column:   pos - bytes.LastIndexByte(file.Data[:pos], '\n'),
+						Name:     AnnotationName(raw),
+						Raw:      raw,
+					})
+				}
+				offset += len(line) + 1
+			}
+		}
+	}
+	return
+}
+
+// isCommentMarker checks text before annotation only contains comment markers and spaces
+func isCommentMarker(str string) bool {
+	str = strings.TrimSpace(str)
+	for _, marker := range []string{"//", "/*"} {
+		str = strings.TrimPrefix(str, marker)
+	}
+	return len(strings.TrimSpace(str)) == 0
+}
@@ -18,9 +18,15 @@
 package zcore
 
 import (
+	"errors"
 	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
 	"os"
+	"path/filepath"
 	"strings"
+	"sync"
 	"testing"
 )
 
@@ -60,11 +66,11 @@ func F0(){}
 )
 
 func TestParse(t *testing.T) {
-	if err := os.WriteFile("test.go", []byte(testParseData), 0o644); err != nil {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "test.go"), []byte(testParseData), 0o644); err != nil {
 		t.Fatal(err)
 	}
-	defer os.Remove("test.go")
-	decls, err := ParseFileOrDirectory(".", AnnotationPrefix)
+	decls, err := ParseFileOrDirectory(dir, AnnotationPrefix)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -74,6 +80,18 @@ func TestParse(t *testing.T) {
 		if !strings.HasSuffix(rel, fmt.Sprintf("%s_%s_%s", "x", decl.Name(), "test.go")) {
 			t.Fatal(rel)
 		}
+		if rel = decl.RelFilename("../{{ .Name }}.go", ""); len(rel) != 0 {
+			t.Fatal(rel)
+		}
+		if _, err = decl.ResolveFilename("../{{ .Name }}.go", ""); err == nil || !strings.Contains(err.Error(), "escapes") {
+			t.Fatal(err)
+		}
+		if err = RenderWrite(test{}, "", rel, "x", false); !errors.Is(err, ErrEmptyFilename) {
+			t.Fatal(err)
+		}
+		if rel = decl.RelFilename("../x.go", ""); rel != filepath.Join(filepath.Dir(dir), "x.go") {
+			t.Fatal(rel)
+		}
 	}
 
 	entities := decls.Parse(test{}, nil)
@@ -83,3 +101,1083 @@ func TestParse(t *testing.T) {
 		}
 	}
 }
+
+const testParseCgoData = `package x
+
+/*
+#include <stdlib.h>
+// +zz:test
+static int add(int a, int b) { return a + b; }
+*/
+import "C"
+
+// +zz:test
+type T struct{}
+
+//export Foo
+func Foo() {}
+`
+
+func TestParseCgo(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "cgo.go")
+	if err := os.WriteFile(filename, []byte(testParseCgoData), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	decls, err := ParseFileDecls(filename, AnnotationPrefix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(decls) != 1 || decls[0].Name() != "T" || len(decls[0].Annotations) != 1 {
+		t.Fatal(decls)
+	}
+}
+
+const testParseBlockCommentData = `package x
+
+/*
+doc line 1
++zz:test:a
+doc line 2
++zz:test:b
+doc line 3
+*/
+type T struct{}
+`
+
+func TestParseBlockComment(t *testing.T) {
+	f, err := parser.ParseFile(token.NewFileSet(), "", testParseBlockCommentData, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	docs, annotations := ParseCommentGroup(AnnotationPrefix, f.Decls[0].(*ast.GenDecl).Doc)
+	if strings.Join(annotations, ",") != "test:a,test:b" {
+		t.Fatal(annotations)
+	}
+	if strings.Join(docs, ",") != "doc line 1,doc line 2,doc line 3" {
+		t.Fatal(docs)
+	}
+}
+
+func TestDeclTypeString(t *testing.T) {
+	for typ, name := range map[int]string{
+		DeclTypeInterface: "interface",
+		DeclTypeFunc:      "functype",
+		DeclFunc:          "func",
+		DeclValue:         "value",
+		0:                 "unknown",
+	} {
+		if ret := DeclTypeString(typ); ret != name {
+			t.Fatal(typ, ret)
+		}
+	}
+}
+
+func TestParseFileOrDirectoryStream(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"a.go", "b.go", "c.go"} {
+		data := testParseCgoData
+		if name == "c.go" {
+			data = "package x\n"
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var files []string
+	if err := ParseFileOrDirectoryStream(dir, AnnotationPrefix, func(file *File, decls AnnotatedDecls) error {
+		if len(decls) != 1 || decls[0].File != file {
+			t.Fatal(decls)
+		}
+		files = append(files, filepath.Base(file.Path))
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(files, ",") != "a.go,b.go" {
+		t.Fatal(files)
+	}
+	fileStore.m.mu.RLock()
+	_, cached := fileStore.m.m[filepath.Join(dir, "a.go")]
+	fileStore.m.mu.RUnlock()
+	if cached {
+		t.Fatal("stream parsing should not cache file data")
+	}
+}
+
+func TestAnnotatedDeclIsAlias(t *testing.T) {
+	f, err := parser.ParseFile(token.NewFileSet(), "", "package x\n\n// +zz:test\ntype A = B\n\n// +zz:test\ntype C B\n", parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decls := parseFileDecls(&File{Ast: f}, AnnotationPrefix)
+	if len(decls) != 2 || !decls[0].IsAlias() || decls[1].IsAlias() || decls[0].Type != DeclTypeRefer || decls[1].Type != DeclTypeRefer {
+		t.Fatal(decls)
+	}
+}
+
+func TestDefaultOutputName(t *testing.T) {
+	decl := &AnnotatedDecl{File: &File{Path: "/x/types.go"}, TypeSpec: &ast.TypeSpec{Name: ast.NewIdent("UserInfo")}}
+	for base, want := range map[string]string{
+		"":                     "types_zz_generated.go",
+		"impl.go":              "impl_zz_generated.go",
+		"impl_zz_generated.go": "impl_zz_generated.go",
+		"{{ snake .Name }}":    "user_info_zz_generated.go",
+	} {
+		if ret := DefaultOutputName(decl, base); ret != want {
+			t.Fatal(base, ret)
+		}
+	}
+}
+
+type testOptionDefaults struct{ test }
+
+func (testOptionDefaults) OptionDefaults() map[string]string {
+	return map[string]string{"a": "default", "b": "default", "c": "default"}
+}
+
+func TestParseOptionDefaults(t *testing.T) {
+	decls := AnnotatedDecls{{Annotations: []string{"test:a=annotation"}}}
+	entities := decls.Parse(testOptionDefaults{}, map[string]string{"a": "command", "b": "command"})
+	if len(entities) != 1 {
+		t.Fatal(entities)
+	}
+	if opt := entities[0].Options; opt["a"] != "annotation" || opt["b"] != "command" || opt["c"] != "default" {
+		t.Fatal(opt)
+	}
+}
+
+type testRunWithOptions struct {
+	testOptionDefaults
+	entities DeclEntities
+	options  Options
+}
+
+func (p *testRunWithOptions) RunWithOptions(entities DeclEntities, options Options) error {
+	p.entities, p.options = entities, options
+	return nil
+}
+
+func TestPluginRunWithOptions(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "a.go"), []byte("package x\n\n// +zz:test:a=annotation\ntype T int\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	p := &testRunWithOptions{}
+	entity := PluginEntity{Plugin: p, Options: map[string]string{"b": "command"}}
+	if err := (PluginEntities{entity}).Run(dir); err != nil {
+		t.Fatal(err)
+	}
+	if len(p.entities) != 1 || p.entities[0].Options["a"] != "annotation" || p.entities[0].Options["b"] != "command" || p.entities[0].Options["c"] != "default" {
+		t.Fatal(p.entities)
+	}
+	if opt := p.options; opt["a"] != "default" || opt["b"] != "command" || opt["c"] != "default" {
+		t.Fatal(opt)
+	}
+	if entity.Options["a"] != "" {
+		t.Fatal(entity.Options)
+	}
+}
+
+func TestCollectAnnotations(t *testing.T) {
+	dir := t.TempDir()
+	data := testParseBlockCommentData + "\n// +zz:typo@1:x\n// see +zz:none\nvar V int\n"
+	if err := os.WriteFile(filepath.Join(dir, "a.go"), []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	// file without annotations is not parsed
+	if err := os.WriteFile(filepath.Join(dir, "invalid.go"), []byte("package"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	refs, err := CollectAnnotations(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(refs) != 3 {
+		t.Fatal(refs)
+	}
+	for i, ref := range []AnnotationRef{
+		{Line: 5, Column: 1, Name: "test", Raw: "test:a"},
+		{Line: 7, Column: 1, Name: "test", Raw: "test:b"},
+		{Line: 12, Column: 4, Name: "typo", Raw: "typo@1:x"},
+	} {
+		ref.Filename = refs[i].Filename
+		if refs[i] != ref || filepath.Base(ref.Filename) != "a.go" {
+			t.Fatal(refs[i])
+		}
+	}
+}
+
+func TestAnnotationStats(t *testing.T) {
+	dir := t.TempDir()
+	for name, data := range map[string]string{
+		"a.go":       "package x\n\n// +zz:api\n// +zz:impl:x\ntype A int\n\n// +zz:api:y\ntype B int\n",
+		"sub/b.go":   "package sub\n\n// +yy:api\n// see +zz:none\ntype C int\n",
+		"invalid.go": "package",
+	} {
+		filename := filepath.Join(dir, filepath.FromSlash(name))
+		_ = os.MkdirAll(filepath.Dir(filename), 0o755)
+		if err := os.WriteFile(filename, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	stats, err := AnnotationStats(dir, []string{AnnotationPrefix, "+yy:"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stats) != 2 || stats["api"] != 3 || stats["impl"] != 1 {
+		t.Fatal(stats)
+	}
+}
+
+func TestRegisterAnnotationForm(t *testing.T) {
+	RegisterAnnotationForm("gozz:")
+	defer func() {
+		annotationFormsMu.Lock()
+		annotationForms = nil
+		annotationFormsMu.Unlock()
+	}()
+
+	filename := filepath.Join(t.TempDir(), "a.go")
+	data := "package x\n\n// T doc\n//gozz:test:a\n// +zz:test:b\ntype T int\n"
+	if err := os.WriteFile(filename, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	decls, err := ParseFileDecls(filename, AnnotationPrefix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(decls) != 1 || len(decls[0].Annotations) != 2 || decls[0].Annotations[0] != "test:a" || decls[0].Docs[0] != "T doc" {
+		t.Fatal(decls)
+	}
+	refs, err := CollectAnnotations(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(refs) != 2 || refs[0].Line != 4 || refs[0].Column != 1 || refs[0].Raw != "test:a" {
+		t.Fatal(refs)
+	}
+
+	// file only uses registered form
+	dir := t.TempDir()
+	filename = filepath.Join(dir, "b.go")
+	if err = os.WriteFile(filename, []byte("package x\n\n//gozz:test:c\ntype U int\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if decls, err = ParseFileOrDirectory(dir, AnnotationPrefix); err != nil || len(decls) != 1 || decls[0].Annotations[0] != "test:c" {
+		t.Fatal(decls, err)
+	}
+	if filenames, err := ListCandidateFiles(dir, AnnotationPrefix); err != nil || len(filenames) != 1 {
+		t.Fatal(filenames, err)
+	}
+	if decls, err = ParseFS(MemoryFS{"b.go": {Data: []byte("package x\n\n//gozz:test:c\ntype U int\n")}}, ".", AnnotationPrefix); err != nil || len(decls) != 1 {
+		t.Fatal(decls, err)
+	}
+}
+
+func TestAnnotatedFieldTypeString(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "x.go")
+	if err := os.WriteFile(filename, []byte(`package x
+
+// +zz:test
+type T struct {
+	*bytes.Buffer
+	// +zz:test
+	M map[string][]*pkg.T
+	// +zz:test
+	S struct {
+		A int
+	}
+}
+
+// +zz:test
+type I interface {
+	// +zz:test
+	Do(ctx context.Context, v ...int) (err error)
+}
+`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	decls, err := ParseFileDecls(filename, AnnotationPrefix)
+	if err != nil || len(decls) != 2 {
+		t.Fatal(decls, err)
+	}
+	var ret []string
+	for _, decl := range decls {
+		for _, field := range decl.Fields {
+			ret = append(ret, field.TypeString())
+		}
+	}
+	embedded := &AnnotatedField{Decl: decls[0], Field: decls[0].TypeSpec.Type.(*ast.StructType).Fields.List[0]}
+	ret = append(ret, embedded.TypeString())
+	if strings.Join(ret, "|") != "map[string][]*pkg.T|struct {\n\t\tA int\n\t}|func(ctx context.Context, v ...int) (err error)|*bytes.Buffer" {
+		t.Fatal(ret)
+	}
+}
+
+func TestAnnotatedFieldTypePackagePath(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "x.go")
+	if err := os.WriteFile(filename, []byte(`package x
+
+import (
+	"bytes"
+	pb "host.com/x/proto"
+)
+
+// +zz:test
+type T struct {
+	// +zz:test
+	A map[string][]*pb.T
+	// +zz:test
+	B *bytes.Buffer
+	// +zz:test
+	C int
+	// +zz:test
+	D unknown.T
+}
+`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	decls, err := ParseFileDecls(filename, AnnotationPrefix)
+	if err != nil || len(decls) != 1 || len(decls[0].Fields) != 4 {
+		t.Fatal(decls, err)
+	}
+	var ret []string
+	for _, field := range decls[0].Fields {
+		p, ok := field.TypePackagePath()
+		ret = append(ret, fmt.Sprintf("%s:%v", p, ok))
+	}
+	if strings.Join(ret, "|") != "host.com/x/proto:true|bytes:true|:false|:false" {
+		t.Fatal(ret)
+	}
+}
+
+func TestAnnotatedFieldDefaultValue(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "x.go")
+	if err := os.WriteFile(filename, []byte(`package x
+
+// +zz:test
+type T struct {
+	// +zz:test
+	A string `+"`default:\"a \\\"b\\\"\"`"+`
+	// +zz:test
+	B int `+"`default:\"0x10\"`"+`
+	// +zz:test
+	C float64 `+"`default:\"1.5\"`"+`
+	// +zz:test
+	D bool `+"`default:\"1\"`"+`
+	// +zz:test
+	E int `+"`default:\"x\"`"+`
+	// +zz:test
+	F int `+"`json:\"f\"`"+`
+	// +zz:test
+	G *int `+"`default:\"1\"`"+`
+	// +zz:test
+	H int8 `+"`default:\"300\"`"+`
+	// +zz:test
+	I uint8 `+"`default:\"255\"`"+`
+	// +zz:test
+	J float32 `+"`default:\"1e39\"`"+`
+	// +zz:test
+	K float64 `+"`default:\"NaN\"`"+`
+	// +zz:test
+	L float64 `+"`default:\"Inf\"`"+`
+	// +zz:test
+	M float32 `+"`default:\"+Inf\"`"+`
+	// +zz:test
+	N float64 `+"`default:\"-infinity\"`"+`
+	// +zz:test
+	O float64 `+"`default:\"0x1p-2\"`"+`
+}
+`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	decls, err := ParseFileDecls(filename, AnnotationPrefix)
+	if err != nil || len(decls) != 1 || len(decls[0].Fields) != 15 {
+		t.Fatal(decls, err)
+	}
+	var ret []string
+	for _, field := range decls[0].Fields {
+		expr, ok := field.DefaultValue()
+		ret = append(ret, fmt.Sprintf("%s:%v", expr, ok))
+	}
+	if strings.Join(ret, "|") != `"a \"b\"":true|0x10:true|1.5:true|true:true|:false|:false|:false|:false|255:true|:false|:false|:false|:false|:false|0x1p-2:true` {
+		t.Fatal(ret)
+	}
+}
+
+type testArgsArity struct{ test }
+
+func (testArgsArity) ArgsArity() (min, max int) { return 1, 2 }
+
+func TestParseArgsArity(t *testing.T) {
+	decls := AnnotatedDecls{{Annotations: []string{"test", "test:a", "test:a:b", "test:a:b:c"}}}
+	var ret []string
+	for _, entity := range decls.Parse(testArgsArity{}, nil) {
+		ret = append(ret, strings.Join(entity.Args, ","))
+	}
+	if strings.Join(ret, "|") != "a|a,b|a,b" {
+		t.Fatal(ret)
+	}
+}
+
+type testArgsArityRun struct {
+	testArgsArity
+	entities DeclEntities
+}
+
+func (p *testArgsArityRun) Args() ([]string, map[string]string) { return []string{"a", "b"}, nil }
+
+func (p *testArgsArityRun) Run(entities DeclEntities) error {
+	p.entities = entities
+	return nil
+}
+
+func TestPluginRunArgsArity(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "a.go"), []byte("package x\n\n// +zz:test:a\n// +zz:test:a:b\ntype T int\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	p := &testArgsArityRun{}
+	if err := (PluginEntities{{Plugin: p}}).Run(dir); err != nil {
+		t.Fatal(err)
+	}
+	var ret []string
+	for _, entity := range p.entities {
+		ret = append(ret, strings.Join(entity.Args, ","))
+	}
+	if strings.Join(ret, "|") != "a|a,b" {
+		t.Fatal(ret)
+	}
+}
+
+type testRunRecorder struct {
+	test
+	runs *[]string
+}
+
+func (p testRunRecorder) Run(DeclEntities) error {
+	*p.runs = append(*p.runs, p.Name())
+	return nil
+}
+
+type testRequires struct {
+	testRunRecorder
+	tools []string
+}
+
+func (p testRequires) Requires() []string { return p.tools }
+
+func TestPluginRunPreflight(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "a.go"), []byte("package x\n\n// +zz:test\ntype T int\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	var runs []string
+	entities := PluginEntities{
+		{Plugin: testRunRecorder{runs: &runs}},
+		{Plugin: testRequires{testRunRecorder: testRunRecorder{runs: &runs}, tools: []string{"go", "gozz-missing-tool"}}},
+	}
+	err := entities.Run(dir)
+	if err == nil || !strings.Contains(err.Error(), `"gozz-missing-tool"`) || len(runs) != 0 {
+		t.Fatal(err, runs)
+	}
+
+	entities[1].Plugin = testRequires{testRunRecorder: testRunRecorder{runs: &runs}, tools: []string{"go"}}
+	if err = entities.Run(dir); err != nil || len(runs) != 2 {
+		t.Fatal(err, runs)
+	}
+}
+
+func TestAnnotatedDeclFuncSignature(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "x.go")
+	if err := os.WriteFile(filename, []byte(`package x
+
+// +zz:test
+func Handler(ctx context.Context, a, b int, opts ...Option) (resp *Resp, err error) { return }
+
+// +zz:test
+func (s *Service) Do(int) error { return nil }
+
+// +zz:test
+type T int
+`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	decls, err := ParseFileDecls(filename, AnnotationPrefix)
+	if err != nil || len(decls) != 3 {
+		t.Fatal(decls, err)
+	}
+
+	recv, params, results, ok := decls[0].FuncSignature()
+	if !ok || recv != nil || len(params) != 4 || len(results) != 2 {
+		t.Fatal(recv, params, results, ok)
+	}
+	if params[2] != (Param{Name: "b", Type: "int"}) || params[3] != (Param{Name: "opts", Type: "...Option", Variadic: true}) ||
+		results[0] != (Param{Name: "resp", Type: "*Resp"}) {
+		t.Fatal(params, results)
+	}
+
+	recv, params, results, ok = decls[1].FuncSignature()
+	if !ok || recv == nil || *recv != (Param{Name: "s", Type: "*Service"}) || params[0] != (Param{Type: "int"}) || results[0] != (Param{Type: "error"}) {
+		t.Fatal(recv, params, results, ok)
+	}
+
+	if _, _, _, ok = decls[2].FuncSignature(); ok {
+		t.Fatal(ok)
+	}
+}
+
+func TestListCandidateFiles(t *testing.T) {
+	dir := t.TempDir()
+	for name, data := range map[string]string{
+		"a.go":              testParseCgoData,
+		"b.go":              "package x\n",
+		"c.txt":             testParseCgoData,
+		"vendor/d.go":       testParseCgoData,
+		".hidden/e.go":      testParseCgoData,
+		"sub/f.go":          testParseCgoData,
+		"sub/testdata/g.go": testParseCgoData,
+	} {
+		filename := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(filename), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filename, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	filenames, err := ListCandidateFiles(dir, AnnotationPrefix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range filenames {
+		filenames[i], _ = filepath.Rel(dir, filenames[i])
+	}
+	if strings.Join(filenames, ",") != "a.go,"+filepath.Join("sub", "f.go") {
+		t.Fatal(filenames)
+	}
+}
+
+func TestParseSkipOptions(t *testing.T) {
+	decls := AnnotatedDecls{{Annotations: []string{"test:a", "test:b:skip", "test:c:ignore=true", "test:d:skip=false"}}}
+	raws := func(entities DeclEntities) (ret []string) {
+		for _, entity := range entities {
+			ret = append(ret, entity.Raw)
+		}
+		return
+	}
+	if ret := raws(decls.Parse(test{}, nil)); len(ret) != 4 {
+		t.Fatal(ret)
+	}
+	if ret := raws(decls.ParseWithConfig(test{}, nil, ParseConfig{SkipOptions: true})); strings.Join(ret, ",") != "test:a,test:d:skip=false" {
+		t.Fatal(ret)
+	}
+}
+
+func TestParseGroupOnly(t *testing.T) {
+	decls, err := ParseSource("x.go", []byte(`package x
+
+// +zz:foo:only=V1,V3
+// +zz:bar
+var (
+	V1 = 1
+	V2 = 2
+	// +zz:baz
+	V3, V4 = 3, 4
+)
+`), AnnotationPrefix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ret []string
+	for _, decl := range decls {
+		ret = append(ret, decl.ValueSpec.Names[0].Name+"="+strings.Join(decl.Annotations, ","))
+	}
+	if strings.Join(ret, " ") != "V1=foo:only=V1,V3,bar V2=bar V3=foo:only=V1,V3,bar,baz" {
+		t.Fatal(ret)
+	}
+}
+
+func TestParseDirectives(t *testing.T) {
+	RegisterAnnotationForm("gozz:")
+	defer func() {
+		annotationFormsMu.Lock()
+		annotationForms = nil
+		annotationFormsMu.Unlock()
+	}()
+
+	decls, err := ParseSource("x.go", []byte(`package x
+
+// Foo does something
+//
+//go:noinline
+//gozz:foo
+//lint:ignore U1000 unused
+func Foo() {}
+
+// +zz:foo
+//go:generate echo
+type T int
+
+// +zz:foo
+var V = 1
+`), AnnotationPrefix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(decls) != 3 {
+		t.Fatal(decls)
+	}
+	if d := decls[0]; strings.Join(d.Directives, "|") != "//go:noinline|//lint:ignore U1000 unused" || d.Docs[0] != "Foo does something" || d.Annotations[0] != "foo" {
+		t.Fatal(d.Directives, d.Docs, d.Annotations)
+	}
+	if d := decls[1]; strings.Join(d.Directives, "|") != "//go:generate echo" {
+		t.Fatal(d.Directives)
+	}
+	if d := decls[2]; len(d.Directives) != 0 {
+		t.Fatal(d.Directives)
+	}
+}
+
+func TestParseExportedOnly(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "x.go")
+	if err := os.WriteFile(filename, []byte(`package x
+
+// +zz:test
+type T struct {
+	// +zz:test
+	A int
+	// +zz:test
+	b int
+	// +zz:test
+	c, D int
+}
+
+// +zz:test
+type t struct {
+	// +zz:test
+	A int
+}
+
+// +zz:test
+func F() {}
+
+// +zz:test
+func f() {}
+
+// +zz:test
+var v, V = 1, 2
+
+// +zz:test
+var w = 1
+`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	decls, err := ParseFileDecls(filename, AnnotationPrefix)
+	if err != nil || len(decls) != 6 {
+		t.Fatal(decls, err)
+	}
+	if entities := decls.Parse(test{}, nil); len(entities) != 6 || len(entities[0].Fields) != 3 {
+		t.Fatal(entities)
+	}
+	entities := decls.ParseWithConfig(test{}, nil, ParseConfig{ExportedOnly: true})
+	if len(entities) != 3 || entities[0].Name() != "T" || entities[1].Name() != "F" || entities[2].Type != DeclValue {
+		t.Fatal(entities)
+	}
+	if len(entities[0].Fields) != 2 || len(decls[0].Fields) != 3 {
+		t.Fatal(entities[0].Fields)
+	}
+}
+
+func TestRegisterAnnotationRewriter(t *testing.T) {
+	RegisterAnnotationRewriter(func(decl *AnnotatedDecl, annotations []string) (ret []string) {
+		for _, annotation := range annotations {
+			if annotation == "crud" {
+				ret = append(ret, "test:create", "test:read")
+				continue
+			}
+			ret = append(ret, annotation)
+		}
+		return
+	})
+	defer func() {
+		annotationRewritersMu.Lock()
+		annotationRewriters = nil
+		annotationRewritersMu.Unlock()
+	}()
+
+	decls := AnnotatedDecls{{Annotations: []string{"crud", "test:x"}}}
+	entities := decls.Parse(test{}, nil)
+	if len(entities) != 3 || entities[0].Raw != "test:create" || entities[1].Raw != "test:read" || entities[2].Raw != "test:x" {
+		t.Fatal(entities)
+	}
+	if len(decls[0].Annotations) != 2 || entities[0].Annotations[0] != "test:create" {
+		t.Fatal(decls[0].Annotations)
+	}
+}
+
+func TestOnFileParsed(t *testing.T) {
+	dir := t.TempDir()
+	for name, data := range map[string]string{
+		"a.go":      "package x\n\n// +zz:test\ntype A int\n",
+		"b.go":      "package x\n\ntype B int\n",
+		"a_test.go": "package x\n",
+		"sub/c.go":  "package sub\n",
+		"sub/d.txt": "",
+	} {
+		filename := filepath.Join(dir, filepath.FromSlash(name))
+		_ = os.MkdirAll(filepath.Dir(filename), 0o755)
+		if err := os.WriteFile(filename, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var progress []string
+	OnFileParsed = func(path string, index, total int) {
+		rel, _ := filepath.Rel(dir, path)
+		progress = append(progress, fmt.Sprintf("%s:%d/%d", filepath.ToSlash(rel), index, total))
+	}
+	defer func() { OnFileParsed, ProgressCountFiles = nil, false }()
+
+	if _, err := ParseFileOrDirectory(dir, AnnotationPrefix); err != nil {
+		t.Fatal(err)
+	}
+	if ret := strings.Join(progress, ","); ret != "a.go:1/0,b.go:2/0,sub/c.go:3/0" {
+		t.Fatal(ret)
+	}
+
+	progress, ProgressCountFiles = nil, true
+	if _, err := ParseFileOrDirectoryMulti(dir, []string{AnnotationPrefix}); err != nil {
+		t.Fatal(err)
+	}
+	if ret := strings.Join(progress, ","); ret != "a.go:1/3,b.go:2/3,sub/c.go:3/3" {
+		t.Fatal(ret)
+	}
+}
+
+func TestParseSkipGeneratedFiles(t *testing.T) {
+	dir := t.TempDir()
+	for name, data := range map[string]string{
+		"src.go":      "package x\n\n// +zz:test\ntype T struct{}\n",
+		"test_gen.go": "// Code generated by gozz:test github.com/go-zing/gozz. DO NOT EDIT.\n\npackage x\n\n// +zz:test\ntype T2 struct{}\n",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	decls, err := ParseFileOrDirectory(dir, AnnotationPrefix)
+	if err != nil || len(decls) != 1 || decls[0].Name() != "T" {
+		t.Fatal(decls, err)
+	}
+
+	// explicit file is always parsed
+	if decls, err = ParseFileOrDirectory(filepath.Join(dir, "test_gen.go"), AnnotationPrefix); err != nil || len(decls) != 1 {
+		t.Fatal(decls, err)
+	}
+
+	fsys := os.DirFS(dir)
+	check := func(want int) {
+		if decls, err = ParseFS(fsys, ".", AnnotationPrefix); err != nil || len(decls) != want {
+			t.Fatal(decls, err)
+		}
+		if filenames, err := ListCandidateFiles(dir, AnnotationPrefix); err != nil || len(filenames) != want {
+			t.Fatal(filenames, err)
+		}
+		if refs, err := CollectAnnotations(dir); err != nil || len(refs) != want {
+			t.Fatal(refs, err)
+		}
+		if stats, err := AnnotationStats(dir, []string{AnnotationPrefix}); err != nil || stats["test"] != want {
+			t.Fatal(stats, err)
+		}
+	}
+	check(1)
+
+	SkipGeneratedFiles = false
+	defer func() { SkipGeneratedFiles = true }()
+	if decls, err = ParseFileOrDirectory(dir, AnnotationPrefix); err != nil || len(decls) != 2 {
+		t.Fatal(decls, err)
+	}
+	check(2)
+}
+
+func TestParseSource(t *testing.T) {
+	decls, err := ParseSource("virtual/test.go", []byte("\xef\xbb\xbf"+testParseData), AnnotationPrefix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(decls) == 0 || decls[0].File.Path != "virtual/test.go" || decls[0].Package() != "x" {
+		t.Fatal(decls)
+	}
+	if _, err = ParseSource("x.go", []byte("package"), AnnotationPrefix); err == nil {
+		t.Fatal("invalid source")
+	}
+}
+
+func TestParseFS(t *testing.T) {
+	fsys := MemoryFS{
+		"x/a.go":            {Data: []byte("package x\n\n// +zz:test\ntype A int\n")},
+		"x/b.go":            {Data: []byte("package x\n\ntype B int\n")},
+		"x/a_test.go":       {Data: []byte("package x\n\n// +zz:test\ntype C int\n")},
+		"x/sub/d.go":        {Data: []byte("package sub\n\n// +zz:test\ntype D int\n")},
+		"x/vendor/e.go":     {Data: []byte("package e\n\n// +zz:test\ntype E int\n")},
+		"x/.hidden/f.go":    {Data: []byte("package f\n\n// +zz:test\ntype F int\n")},
+		"y/g.go":            {Data: []byte("package y\n\n// +zz:test\ntype G int\n")},
+		"x/sub/invalid.txt": {Data: []byte("// +zz:test")},
+	}
+	decls, err := ParseFS(fsys, "x", AnnotationPrefix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, decl := range decls {
+		names = append(names, decl.File.Path+":"+decl.Name())
+	}
+	if strings.Join(names, ",") != "x/a.go:A,x/sub/d.go:D" {
+		t.Fatal(names)
+	}
+	if _, err = ParseFS(fsys, "z", AnnotationPrefix); err == nil {
+		t.Fatal("not exist root")
+	}
+}
+
+func TestParseTagAnnotations(t *testing.T) {
+	AnnotationTagKey = "gozz"
+	defer func() { AnnotationTagKey = "" }()
+
+	filename := filepath.Join(t.TempDir(), "x.go")
+	if err := os.WriteFile(filename, []byte("package x\n\n// +zz:test\ntype T struct {\n"+
+		"\t// +zz:test:comment\n\tA int `json:\"a\" gozz:\"test:a\\\\:b; test:c\\\\;d\"`\n"+
+		"\tB int `json:\"b\"`\n"+
+		"\tC int `gozz:\"test:c\"`\n}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	decls, err := ParseFileDecls(filename, AnnotationPrefix)
+	if err != nil || len(decls) != 1 || len(decls[0].Fields) != 2 {
+		t.Fatal(decls, err)
+	}
+	if annotations := decls[0].Fields[0].Annotations; strings.Join(annotations, "|") != `test:comment|test:a\:b|test:c;d` {
+		t.Fatal(annotations)
+	}
+	entities := decls[0].parse("test", 0, nil)
+	var args []string
+	for _, field := range entities[0].ParseFields(1, nil) {
+		args = append(args, field.Args[0])
+	}
+	if strings.Join(args, "|") != "comment|a:b|c;d|c" {
+		t.Fatal(args)
+	}
+}
+
+func TestParseCacheSettings(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "x.go")
+	if err := os.WriteFile(filename, []byte("package x\n\n//gozz:test\ntype T struct {\n\tA int `gozz:\"test\"`\n}\n\n// +zz:test\ntype U int\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	count := func() (decls, fields int) {
+		ret, err := ParseFileDecls(filename, AnnotationPrefix)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, decl := range ret {
+			fields += len(decl.Fields)
+		}
+		return len(ret), fields
+	}
+	if decls, fields := count(); decls != 1 || fields != 0 {
+		t.Fatal(decls, fields)
+	}
+
+	RegisterAnnotationForm("gozz:")
+	defer func() {
+		annotationFormsMu.Lock()
+		annotationForms = nil
+		annotationFormsMu.Unlock()
+	}()
+	if decls, fields := count(); decls != 2 || fields != 0 {
+		t.Fatal(decls, fields)
+	}
+
+	AnnotationTagKey = "gozz"
+	defer func() { AnnotationTagKey = "" }()
+	if decls, fields := count(); decls != 2 || fields != 1 {
+		t.Fatal(decls, fields)
+	}
+}
+
+func TestAnnotatedDeclsIndex(t *testing.T) {
+	a, b := &File{Path: filepath.Join("a", "x.go")}, &File{Path: filepath.Join("b", "x.go")}
+	newDecl := func(file *File, name string) *AnnotatedDecl {
+		return &AnnotatedDecl{File: file, TypeSpec: &ast.TypeSpec{Name: ast.NewIdent(name)}}
+	}
+	decls := AnnotatedDecls{newDecl(a, "T"), newDecl(a, "U"), newDecl(b, "T"), {File: b}}
+	if index := decls.Index(); len(index) != 2 || index["T"] != decls[0] || index["U"] != decls[1] {
+		t.Fatal(index)
+	}
+	if index := decls.IndexByDir(); len(index) != 2 || index["b"]["T"] != decls[2] || len(index["b"]) != 1 {
+		t.Fatal(index)
+	}
+}
+
+type testNamed struct {
+	test
+	name string
+}
+
+func (p testNamed) Name() string { return p.name }
+
+func TestUnregisterPlugin(t *testing.T) {
+	name := "test-unregister"
+	defer UnregisterPlugin(name)
+
+	RegisterPlugin(testNamed{name: name})
+	if _, ok := PluginRegistry()[name]; !ok {
+		t.Fatal("registered")
+	}
+	UnregisterPlugin(name)
+	if _, ok := PluginRegistry()[name]; ok {
+		t.Fatal("unregistered")
+	}
+
+	// unknown name
+	size := len(PluginRegistry())
+	UnregisterPlugin("test-unknown")
+	if len(PluginRegistry()) != size {
+		t.Fatal("unknown name")
+	}
+
+	// register again
+	RegisterPlugin(testNamed{name: name})
+	if p, ok := PluginRegistry()[name]; !ok || p.Name() != name {
+		t.Fatal("registered again")
+	}
+}
+
+func TestPluginRegistrySnapshot(t *testing.T) {
+	name := "test-snapshot"
+	RegisterPlugin(testNamed{name: name})
+	defer UnregisterPlugin(name)
+
+	m := PluginRegistry()
+	delete(m, name)
+	m["test-injected"] = test{}
+	if r := PluginRegistry(); r[name] == nil || r["test-injected"] != nil {
+		t.Fatal(r)
+	}
+}
+
+func TestPluginRegistryConcurrent(t *testing.T) {
+	wg := sync.WaitGroup{}
+	for i := 0; i < 8; i++ {
+		name := fmt.Sprintf("test-concurrent-%d", i)
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			RegisterPlugin(testNamed{name: name})
+			RegisterPluginAlias(name+"-old", name)
+		}()
+		go func() {
+			defer wg.Done()
+			_ = len(PluginRegistry())
+			_ = PluginAliases(name)
+		}()
+	}
+	wg.Wait()
+	for i := 0; i < 8; i++ {
+		name := fmt.Sprintf("test-concurrent-%d", i)
+		if PluginRegistry()[name] == nil || len(PluginAliases(name)) != 1 {
+			t.Fatal(name)
+		}
+		UnregisterPlugin(name)
+		pluginRegistryMu.Lock()
+		delete(pluginAliases, name+"-old")
+		pluginRegistryMu.Unlock()
+	}
+}
+
+func TestRegisterPluginAlias(t *testing.T) {
+	RegisterPluginAlias("old", "test")
+	defer func() {
+		pluginRegistryMu.Lock()
+		delete(pluginAliases, "old")
+		pluginRegistryMu.Unlock()
+	}()
+	if aliases := PluginAliases("test"); len(aliases) != 1 || aliases[0] != "old" {
+		t.Fatal(aliases)
+	}
+
+	decl := &AnnotatedDecl{Annotations: []string{"old:a", "test:b", "other:c"}}
+	decl.Fields = []*AnnotatedField{{Decl: decl, Annotations: []string{"old:x"}}}
+	entities := AnnotatedDecls{decl}.Parse(testArgsArity{}, nil)
+	if len(entities) != 2 || entities[0].Plugin != "test" || entities[0].Args[0] != "a" || entities[1].Args[0] != "b" {
+		t.Fatal(entities)
+	}
+	if fields := entities[0].ParseFields(1, nil); len(fields) != 1 || fields[0].Args[0] != "x" {
+		t.Fatal(fields)
+	}
+}
+
+const testParseIndentedBlockCommentData = `package x
+
+/*
+    doc line 1
+      +zz:test:a
+    doc line 2
+        indented doc line
+	+zz:test:b
+
+    doc line 3
+*/
+type T struct{}
+
+// doc line
+//   +zz:test:c
+//   indented doc line
+type U struct{}
+`
+
+func TestParseIndentedBlockComment(t *testing.T) {
+	f, err := parser.ParseFile(token.NewFileSet(), "", testParseIndentedBlockCommentData, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	docs, annotations := ParseCommentGroup(AnnotationPrefix, f.Decls[0].(*ast.GenDecl).Doc)
+	if strings.Join(annotations, ",") != "test:a,test:b" {
+		t.Fatal(annotations)
+	}
+	if strings.Join(docs, "|") != "doc line 1|doc line 2|    indented doc line||doc line 3" {
+		t.Fatalf("%q", docs)
+	}
+
+	docs, annotations = ParseCommentGroup(AnnotationPrefix, f.Decls[1].(*ast.GenDecl).Doc)
+	if strings.Join(annotations, ",") != "test:c" || strings.Join(docs, "|") != "doc line|  indented doc line" {
+		t.Fatalf("%q %q", docs, annotations)
+	}
+}
+
+func TestParseFileOrDirectoryMulti(t *testing.T) {
+	dir := t.TempDir()
+	for name, data := range map[string]string{
+		"a.go":     "package x\n\n// +zz:test\n// +di:provide\ntype A struct{}\n",
+		"sub/b.go": "package sub\n\n// +di:provide\ntype B struct{}\n",
+	} {
+		filename := filepath.Join(dir, filepath.FromSlash(name))
+		_ = os.MkdirAll(filepath.Dir(filename), 0o755)
+		if err := os.WriteFile(filename, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	decls, err := ParseFileOrDirectoryMulti(dir, []string{AnnotationPrefix, "+di:", "+none:"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if zz := decls[AnnotationPrefix]; len(zz) != 1 || zz[0].Annotations[0] != "test" {
+		t.Fatal(zz)
+	}
+	if di := decls["+di:"]; len(di) != 2 || di[0].Annotations[0] != "provide" || di[1].Name() != "B" {
+		t.Fatal(di)
+	}
+	if len(decls["+none:"]) != 0 {
+		t.Fatal(decls)
+	}
+}
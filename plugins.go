@@ -18,7 +18,11 @@
 package zcore
 
 import (
+	"fmt"
+	"os/exec"
 	"plugin"
+	"sort"
+	"sync"
 )
 
 const (
@@ -58,6 +62,32 @@ type (
 		Run(entities DeclEntities) (err error)
 	}
 
+	// PluginRequires represents optional interface of Plugin to declare required external executables.
+	// executables would be checked in PATH before any plugin runs
+	PluginRequires interface {
+		Requires() []string
+	}
+
+	// PluginArgsArity represents optional interface of Plugin to accept optional or variadic trailing args.
+	// annotations with args count from min to max would be matched. negative max means no limit.
+	// optional args would be taken until max or first element containing "=" as option
+	PluginArgsArity interface {
+		ArgsArity() (min, max int)
+	}
+
+	// PluginOptionDefaults represents optional interface of Plugin to declare default values of options.
+	// defaults would be merged into parsed options below command options and annotation options
+	PluginOptionDefaults interface {
+		OptionDefaults() map[string]string
+	}
+
+	// PluginRunWithOptions represents optional interface of Plugin to receive invocation level options.
+	// RunWithOptions would be called instead of Run with resolved options from execute command
+	// which are distinct from per-annotation options of each entity
+	PluginRunWithOptions interface {
+		RunWithOptions(entities DeclEntities, options Options) (err error)
+	}
+
 	// PluginEntity represents Plugin instance and extra options from execute command
 	PluginEntity struct {
 		Plugin
@@ -67,31 +97,119 @@ type (
 	PluginEntities []PluginEntity
 )
 
-// plugin provides simple registry store for all registered plugins with name
-var pluginRegistry = map[string]Plugin{}
+var (
+	// plugin provides simple registry store for all registered plugins with name
+	pluginRegistry = map[string]Plugin{}
+	// pluginRegistryMu guards pluginRegistry and pluginAliases for concurrent registrations
+	pluginRegistryMu sync.RWMutex
+
+	// pluginAliases provides deprecated plugin names with new name as value
+	pluginAliases = map[string]string{}
+
+	// WarnDeprecatedAlias prints warning with Logger when annotation matched by deprecated plugin alias
+	WarnDeprecatedAlias = true
+)
+
+// RegisterPluginAlias registers deprecated plugin name as alias of new plugin name.
+// annotations with old name would be parsed as annotations of new plugin
+func RegisterPluginAlias(old, new string) {
+	pluginRegistryMu.Lock()
+	pluginAliases[old] = new
+	pluginRegistryMu.Unlock()
+}
+
+// PluginAliases returns sorted deprecated alias names of plugin name
+func PluginAliases(name string) (aliases []string) {
+	pluginRegistryMu.RLock()
+	defer pluginRegistryMu.RUnlock()
+	for old, n := range pluginAliases {
+		if n == name {
+			aliases = append(aliases, old)
+		}
+	}
+	sort.Strings(aliases)
+	return
+}
 
-func PluginRegistry() map[string]Plugin { return pluginRegistry }
+// PluginRegistry returns snapshot of all registered plugins with name
+func PluginRegistry() map[string]Plugin {
+	pluginRegistryMu.RLock()
+	defer pluginRegistryMu.RUnlock()
+	m := make(map[string]Plugin, len(pluginRegistry))
+	for name, plugin := range pluginRegistry {
+		m[name] = plugin
+	}
+	return m
+}
 
+// RegisterPlugin registers Plugin to pluginRegistry
 func RegisterPlugin(plugin Plugin) {
+	pluginRegistryMu.Lock()
 	pluginRegistry[plugin.Name()] = plugin
+	pluginRegistryMu.Unlock()
 }
 
+// UnregisterPlugin removes registered Plugin from pluginRegistry by name
+func UnregisterPlugin(name string) {
+	pluginRegistryMu.Lock()
+	delete(pluginRegistry, name)
+	pluginRegistryMu.Unlock()
+}
+
+// Run runs all plugin entities with parsed filename in order.
+// in strict resolution mode collected warnings would be returned as error after each plugin run
 func (entities PluginEntities) Run(filename string) (err error) {
+	if err = entities.Preflight(); err != nil {
+		return
+	}
+	_ = TakeWarnings()
 	for _, entity := range entities {
 		if err = entity.run(filename); err != nil {
 			return
 		}
+		if err = TakeWarnings(); err != nil {
+			return fmt.Errorf("plugin %s: %w", entity.Name(), err)
+		}
+	}
+	return
+}
+
+// Preflight checks required executables of plugins which implements PluginRequires exist in PATH
+func (entities PluginEntities) Preflight() (err error) {
+	for _, entity := range entities {
+		requires, ok := entity.Plugin.(PluginRequires)
+		if !ok {
+			continue
+		}
+		for _, tool := range requires.Requires() {
+			if _, e := exec.LookPath(tool); e != nil {
+				return fmt.Errorf("plugin %s requires executable %q: %w", entity.Name(), tool, e)
+			}
+		}
 	}
 	return
 }
 
+// ResolvedOptions returns command options of plugin entity merged over plugin option defaults
+func (entity PluginEntity) ResolvedOptions() Options {
+	options := Options(entity.Options).Merge(nil, false)
+	if p, ok := entity.Plugin.(PluginOptionDefaults); ok {
+		options = Options(p.OptionDefaults()).Merge(options, true)
+	}
+	return options
+}
+
 func (entity PluginEntity) run(filename string) (err error) {
 	decls, err := ParseFileOrDirectory(filename, AnnotationPrefix)
 	if err != nil {
 		return
 	}
 	Logger.Printf("running plugin %s\n", entity.Name())
-	return entity.Plugin.Run(decls.Parse(entity, entity.Options))
+	entities := decls.Parse(entity, entity.Options)
+	if p, ok := entity.Plugin.(PluginRunWithOptions); ok {
+		return p.RunWithOptions(entities, entity.ResolvedOptions())
+	}
+	return entity.Plugin.Run(entities)
 }
 
 // LoadExtension load filename and lookup symbol named "Z"
@@ -0,0 +1,174 @@
+/*
+ * Copyright (c) 2023 Maple Wu <justmaplewu@gmail.com>
+ *   National Electronics and Computer Technology Center, Thailand
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package zcore
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+type (
+	// SourceParser represents interface to extract annotated declarations from non-golang source files.
+	// parser could be registered with file extension by RegisterSourceParser
+	SourceParser interface {
+		// Parse parses file data and returns declarations annotated with annotations prefix
+		Parse(filename string, data []byte, prefix string) (decls SourceDecls, err error)
+	}
+
+	// SourceDecl represents annotated declaration in non-golang source file
+	SourceDecl struct {
+		Filename string
+
+		// Line is line number of declaration starts from 1
+		Line int
+
+		// Text is first line text of declaration
+		Text string
+
+		Docs        []string
+		Annotations []string
+	}
+
+	SourceDecls []*SourceDecl
+
+	// SourceEntity represents annotated SourceDecl with parsed args and options
+	SourceEntity struct {
+		*SourceDecl
+
+		Plugin  string
+		Args    []string
+		Options Options
+		Raw     string
+	}
+
+	SourceEntities []SourceEntity
+
+	// LineCommentParser implements SourceParser for sources with line comment like "--" or "#".
+	// continuous comment lines would be treated as docs of next non-empty line as declaration
+	LineCommentParser struct {
+		CommentPrefix string
+	}
+)
+
+var (
+	// sourceParserRegistry provides registry store for all registered SourceParser with file extension
+	sourceParserRegistry = map[string]SourceParser{
+		".sql":   LineCommentParser{CommentPrefix: "--"},
+		".proto": LineCommentParser{CommentPrefix: "//"},
+	}
+	// sourceParserRegistryMu guards sourceParserRegistry for concurrent registrations
+	sourceParserRegistryMu sync.RWMutex
+)
+
+// RegisterSourceParser registers SourceParser with file extension like ".sql"
+func RegisterSourceParser(ext string, parser SourceParser) {
+	sourceParserRegistryMu.Lock()
+	sourceParserRegistry[ext] = parser
+	sourceParserRegistryMu.Unlock()
+}
+
+// GetSourceParser get SourceParser by file extension
+func GetSourceParser(ext string) SourceParser {
+	sourceParserRegistryMu.RLock()
+	defer sourceParserRegistryMu.RUnlock()
+	return sourceParserRegistry[ext]
+}
+
+// Parse implements SourceParser
+func (p LineCommentParser) Parse(filename string, data []byte, prefix string) (decls SourceDecls, err error) {
+	var comments []string
+	for i, line := range strings.Split(UnsafeBytes2String(data), "\n") {
+		line = strings.TrimSpace(line)
+
+		if comment, ok := TrimPrefix(line, p.CommentPrefix); ok {
+			comments = append(comments, strings.TrimSpace(comment))
+			continue
+		}
+
+		// empty line between comments and declaration would be ignored
+		if len(line) == 0 {
+			continue
+		}
+
+		if docs, annotations := SplitAnnotations(prefix, comments); len(annotations) > 0 {
+			decls = append(decls, &SourceDecl{
+				Filename:    filename,
+				Line:        i + 1,
+				Text:        line,
+				Docs:        docs,
+				Annotations: annotations,
+			})
+		}
+		comments = nil
+	}
+	return
+}
+
+// ParseSourceFileOrDirectory try parse provided path non-golang source files annotated declarations
+// with registered SourceParser by file extension. files without registered parser would be ignored.
+// if directory provided. walks file tree from provided path as root and returns all parsed
+func ParseSourceFileOrDirectory(path string, prefix string) (decls SourceDecls, err error) {
+	stat, err := os.Stat(path)
+	if err != nil {
+		return
+	}
+
+	parse := func(filename string) (err error) {
+		parser := GetSourceParser(filepath.Ext(filename))
+		if parser == nil {
+			return
+		}
+		data, _, err := ReadFile(filename)
+		if err != nil || !containsAnnotation(data, prefix) {
+			return
+		}
+		fileDecls, err := parser.Parse(filename, data, prefix)
+		if err != nil {
+			return
+		}
+		decls = append(decls, fileDecls...)
+		return
+	}
+
+	if !stat.IsDir() {
+		return decls, parse(path)
+	}
+	return decls, WalkTree(path, parse)
+}
+
+// Parse parses declarations annotations matched with name and args count. and convert into args and options.
+func (decls SourceDecls) Parse(name string, argsCount int, extOptions map[string]string) (entities SourceEntities) {
+	for _, decl := range decls {
+		for _, annotation := range decl.Annotations {
+			args, opts, ok := parseAnnotation(annotation, name, argsCount, extOptions)
+			if !ok {
+				continue
+			}
+			entities = append(entities, SourceEntity{
+				SourceDecl: decl,
+				Plugin:     name,
+				Args:       args,
+				Options:    opts,
+				Raw:        annotation,
+			})
+		}
+	}
+	return
+}
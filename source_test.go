@@ -0,0 +1,56 @@
+/*
+ * Copyright (c) 2023 Maple Wu <justmaplewu@gmail.com>
+ *   National Electronics and Computer Technology Center, Thailand
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package zcore
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const testSourceSqlData = `-- users table
+-- +zz:test:users:key=value
+CREATE TABLE users (
+    id INT -- +zz:test:ignored
+);
+
+-- not annotated
+CREATE TABLE orders (id INT);
+
+-- +zz:test:logs
+
+CREATE TABLE logs (id INT);
+`
+
+func TestParseSourceFileOrDirectory(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "schema.sql"), []byte(testSourceSqlData), 0o664); err != nil {
+		t.Fatal(err)
+	}
+	decls, err := ParseSourceFileOrDirectory(dir, AnnotationPrefix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(decls) != 2 || decls[0].Line != 3 || decls[0].Text != "CREATE TABLE users (" || len(decls[0].Docs) != 1 || decls[1].Line != 12 {
+		t.Fatal(decls)
+	}
+	entities := decls.Parse("test", 1, nil)
+	if len(entities) != 2 || entities[0].Args[0] != "users" || entities[0].Options["key"] != "value" || entities[1].Args[0] != "logs" {
+		t.Fatal(entities)
+	}
+}
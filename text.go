@@ -20,10 +20,13 @@ package zcore
 import (
 	"bytes"
 	"fmt"
+	"go/token"
 	"reflect"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
+	"unicode"
 	"unsafe"
 )
 
@@ -107,12 +110,56 @@ func (set KeySet) Add(keys []string) {
 
 func UnsafeBytes2String(b []byte) string { return *(*string)(unsafe.Pointer(&b)) }
 
-func UnsafeString2Bytes(s string) []byte {
+func UnsafeString2Bytes(s string) (b []byte) {
 	sh := (*reflect.StringHeader)(unsafe.Pointer(&s))
-	bh := reflect.SliceHeader{
-		Data: sh.Data,
-		Len:  sh.Len,
-		Cap:  sh.Len,
+	bh := (*reflect.SliceHeader)(unsafe.Pointer(&b))
+	bh.Data, bh.Len, bh.Cap = sh.Data, sh.Len, sh.Len
+	return
+}
+
+// NameAllocator allocates unique valid golang identifiers from raw names deterministically.
+// invalid characters would be replaced as "_", identifier starts with digit would be prefixed with "X"
+// and keyword would be suffixed with "_". duplicated identifier would be suffixed as "_2" "_3" ...
+type NameAllocator struct {
+	// Convert converts raw name before allocation such as UpperCamelCase. raw name is used if nil
+	Convert func(string) string
+
+	used map[string]struct{}
+}
+
+// Allocate returns unique valid identifier of raw name
+func (a *NameAllocator) Allocate(raw string) string {
+	name := raw
+	if a.Convert != nil {
+		name = a.Convert(name)
+	}
+
+	name = strings.Map(func(r rune) rune {
+		if r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return r
+		}
+		return '_'
+	}, name)
+
+	switch {
+	case len(name) == 0:
+		name = "X"
+	case unicode.IsDigit([]rune(name)[0]):
+		name = "X" + name
+	case token.IsKeyword(name):
+		name += "_"
+	}
+
+	if a.used == nil {
+		a.used = make(map[string]struct{})
+	}
+	ret := name
+	for i := 2; ; i++ {
+		if _, exist := a.used[ret]; !exist {
+			break
+		}
+		ret = name + "_" + strconv.Itoa(i)
 	}
-	return *(*[]byte)(unsafe.Pointer(&bh)) // nolint
+	a.used[ret] = struct{}{}
+	return ret
 }
@@ -18,6 +18,7 @@
 package zcore
 
 import (
+	"strings"
 	"testing"
 )
 
@@ -62,3 +63,17 @@ func TestSplitKVSlice2Map(t *testing.T) {
 		t.Fatal(m)
 	}
 }
+
+func TestNameAllocator(t *testing.T) {
+	allocator := NameAllocator{Convert: UpperCamelCase}
+	var names []string
+	for _, raw := range []string{"user_id", "userId", "UserID", "user id", "1st", "", "type", "user_id_2"} {
+		names = append(names, allocator.Allocate(raw))
+	}
+	if strings.Join(names, ",") != "UserId,UserId_2,UserId_3,UserId_4,X1st,X,Type,UserId2" {
+		t.Fatal(names)
+	}
+	if name := (&NameAllocator{}).Allocate("type"); name != "type_" {
+		t.Fatal(name)
+	}
+}